@@ -0,0 +1,179 @@
+package artifact
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone/drone-exec/glob"
+)
+
+var ErrPath = errors.New("Artifact path must be relative to the workspace")
+
+// Source provides access to the files in the shared
+// build volume using tar archives.
+type Source interface {
+	// CopyFrom returns a tar archive of the file or
+	// directory at the path.
+	CopyFrom(path string) (io.ReadCloser, error)
+}
+
+// File represents an artifact copied to the host machine.
+type File struct {
+	Path string // path relative to the workspace
+	Size int64  // size in bytes
+}
+
+// WalkFunc is the type of the function called for each
+// workspace file matched by Walk.
+type WalkFunc func(name string, size int64, r io.Reader) error
+
+// Walk calls fn for each workspace file matching the glob
+// patterns, in the order of the patterns. A pattern matching
+// a directory matches every file in the directory, and the
+// ** pattern matches any number of directories, for example:
+//
+//	dist/*.tar.gz
+//	coverage/**/*.xml
+func Walk(src Source, workspace string, patterns []string, fn WalkFunc) error {
+	var seen = map[string]bool{}
+
+	for _, pattern := range patterns {
+		pattern, err := clean(pattern)
+		if err != nil {
+			return err
+		}
+		base := prefix(pattern)
+		rc, err := src.CopyFrom(path.Join(workspace, base))
+		if err != nil {
+			log.Debugf("Unable to read %s. %s", pattern, err)
+			continue
+		}
+		err = walk(rc, pattern, base, seen, fn)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk is a helper function that calls fn for each regular
+// file in the tar archive of the base directory matching
+// the pattern.
+func walk(r io.Reader, pattern, base string, seen map[string]bool, fn WalkFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		// the archive entries are relative to the parent
+		// of the requested path.
+		name := path.Clean(hdr.Name)
+		if len(base) != 0 {
+			name = path.Join(path.Dir(base), name)
+		} else if i := strings.Index(name, "/"); i != -1 {
+			name = name[i+1:]
+		}
+		if _, err := clean(name); err != nil {
+			continue
+		}
+		if seen[name] || !matchParents(pattern, name) {
+			continue
+		}
+		seen[name] = true
+
+		err = fn(name, hdr.Size, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Collect copies the workspace files matching the glob
+// patterns to the directory on the host machine, and returns
+// the copied files.
+func Collect(src Source, workspace string, patterns []string, dir string) ([]*File, error) {
+	var files []*File
+	err := Walk(src, workspace, patterns, func(name string, size int64, r io.Reader) error {
+		err := write(filepath.Join(dir, filepath.FromSlash(name)), r)
+		if err != nil {
+			return err
+		}
+		files = append(files, &File{Path: name, Size: size})
+		return nil
+	})
+	return files, err
+}
+
+// write is a helper function that writes the contents
+// of the reader to the named file.
+func write(name string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// clean is a helper function that returns the cleaned
+// pattern, or an error if the pattern is not relative to
+// the workspace.
+func clean(p string) (string, error) {
+	p = path.Clean(p)
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrPath
+	}
+	if p == "." {
+		p = "**"
+	}
+	return p, nil
+}
+
+// prefix is a helper function that returns the leading
+// directories of the pattern that contain no wildcards.
+func prefix(pattern string) string {
+	var parts []string
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.ContainsAny(part, "*?[\\") {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// matchParents is a helper function that returns true if
+// the pattern matches the name, or a parent directory of
+// the name.
+func matchParents(pattern, name string) bool {
+	for name != "." && name != "/" {
+		if glob.Match(pattern, name) {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
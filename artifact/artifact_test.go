@@ -0,0 +1,104 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Artifact(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Artifacts", func() {
+
+		var dir string
+		var src = source{
+			"/drone/src/dist/app.tar.gz":          "app",
+			"/drone/src/dist/app.txt":             "readme",
+			"/drone/src/coverage/unit/report.xml": "<xml/>",
+			"/drone/src/main.go":                  "package main",
+		}
+
+		g.BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "drone-artifacts-")
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should collect matching files", func() {
+			files, err := Collect(src, "/drone/src", []string{"dist/*.tar.gz"}, dir)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(files)).Equal(1)
+			g.Assert(files[0].Path).Equal("dist/app.tar.gz")
+			g.Assert(files[0].Size).Equal(int64(3))
+
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "dist", "app.tar.gz"))
+			g.Assert(string(data)).Equal("app")
+		})
+
+		g.It("Should collect directories", func() {
+			files, err := Collect(src, "/drone/src", []string{"coverage"}, dir)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(files)).Equal(1)
+			g.Assert(files[0].Path).Equal("coverage/unit/report.xml")
+		})
+
+		g.It("Should collect recursive patterns", func() {
+			files, err := Collect(src, "/drone/src", []string{"**/*.xml", "*.go"}, dir)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(files)).Equal(2)
+			g.Assert(files[0].Path).Equal("coverage/unit/report.xml")
+			g.Assert(files[1].Path).Equal("main.go")
+		})
+
+		g.It("Should skip missing paths", func() {
+			files, err := Collect(src, "/drone/src", []string{"build/*.jar"}, dir)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(files)).Equal(0)
+		})
+
+		g.It("Should reject paths outside the workspace", func() {
+			_, err := Collect(src, "/drone/src", []string{"../*"}, dir)
+			g.Assert(err).Equal(ErrPath)
+		})
+	})
+}
+
+// source is a fake build volume that returns a tar archive
+// of the files at or below the requested path, relative to
+// the parent of the path like the Docker archive API.
+type source map[string]string
+
+func (s source) CopyFrom(p string) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	var found bool
+	tw := tar.NewWriter(&buf)
+	for name, data := range s {
+		if name != p && !strings.HasPrefix(name, p+"/") {
+			continue
+		}
+		found = true
+		tw.WriteHeader(&tar.Header{
+			Name:     strings.TrimPrefix(name, path.Dir(p)+"/"),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(&buf), nil
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultRoot is the default host directory where
+// cached workspace paths are stored.
+const DefaultRoot = "/var/lib/drone/cache"
+
+// keysDir is the directory, relative to the root, where
+// archives are stored by key. The name cannot conflict
+// with the repository directories used by cache plugins.
+const keysDir = ".keys"
+
+// branchesDir is the directory, relative to the root, where
+// the cache plugin directories are stored by branch. The
+// repository directories in the root are the caches used by
+// cache plugins before caches were stored per branch.
+const branchesDir = ".branches"
+
+// savedFile is the marker file, in the key directory, whose
+// modification time records when the key was saved. The key
+// directory itself is touched when the key is restored.
+const savedFile = ".saved"
+
+var (
+	ErrKeyMissing = errors.New("Cache key must not be empty")
+	ErrKeyMiss    = errors.New("Cache key not found")
+	ErrPath       = errors.New("Cache path must be relative to the workspace")
+)
+
+// Volume provides access to the files in the shared
+// build volume using tar archives.
+type Volume interface {
+	// CopyFrom returns a tar archive of the file or
+	// directory at the path. If the path does not exist
+	// the error is os.ErrNotExist.
+	CopyFrom(path string) (io.ReadCloser, error)
+
+	// CopyTo extracts a tar archive into the directory
+	// at the path.
+	CopyTo(path string, r io.Reader) error
+}
+
+// Cache saves and restores workspace paths to and from
+// archives stored on the host machine, keyed by the
+// repository, branch and cache key.
+type Cache struct {
+	Root      string // host directory storing the archives
+	Repo      string // repository full name
+	Branch    string // build branch
+	Default   string // repository default branch
+	Workspace string // workspace path in the build volume
+
+	Volume Volume
+}
+
+// Restore restores the paths from the archives stored for
+// the cache key. If the key is not found, each fallback key
+// is tried in order, matching the most recently saved key
+// with the fallback key as a prefix. Archives saved for the
+// default branch are used when the build branch has none.
+// The matched key is returned.
+func (c *Cache) Restore(key string, fallback []string, paths []string) (string, error) {
+	if len(escapeName(key)) == 0 {
+		return "", ErrKeyMissing
+	}
+	dir, err := c.find(key, fallback)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range paths {
+		p, err := clean(p)
+		if err != nil {
+			return "", err
+		}
+		f, err := os.Open(filepath.Join(dir, escape(p)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		err = c.Volume.CopyTo(path.Dir(path.Join(c.Workspace, p)), f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("Error restoring %s. %s", p, err)
+		}
+	}
+
+	// touch the directory to record the last access time.
+	Touch(dir)
+	return filepath.Base(dir), nil
+}
+
+// Save saves the paths to archives stored for the cache
+// key. Existing keys are not overwritten. Paths that do
+// not exist in the workspace are skipped.
+func (c *Cache) Save(key string, paths []string) error {
+	if len(escapeName(key)) == 0 {
+		return ErrKeyMissing
+	}
+	parent := c.dir(c.Branch)
+	dir := filepath.Join(parent, escapeName(key))
+	if _, err := os.Stat(dir); err == nil {
+		log.Printf("Cache key %s exists, skipping save", key)
+		return nil
+	}
+
+	err := os.MkdirAll(parent, 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(parent, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, p := range paths {
+		p, err := clean(p)
+		if err != nil {
+			return err
+		}
+		err = c.save(path.Join(c.Workspace, p), filepath.Join(tmp, escape(p)))
+		if err != nil {
+			return fmt.Errorf("Error saving %s. %s", p, err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(tmp, savedFile), nil, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
+
+// save is a helper function that writes the tar archive
+// of the path in the build volume to the named file. Paths
+// that do not exist are skipped.
+func (c *Cache) save(p, name string) error {
+	rc, err := c.Volume.CopyFrom(p)
+	if os.IsNotExist(err) {
+		log.Debugf("Unable to cache %s. %s", p, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = copyArchive(f, rc)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// copyArchive is a helper function that copies the tar
+// archive to w, and returns an error if the archive is
+// truncated or malformed.
+func copyArchive(w io.Writer, r io.Reader) error {
+	tr := tar.NewReader(io.TeeReader(r, w))
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(ioutil.Discard, tr)
+		if err != nil {
+			return err
+		}
+	}
+	// copies the padding following the end of the archive.
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// find is a helper function that returns the directory of
+// the cache key, or the best matching fallback key.
+func (c *Cache) find(key string, fallback []string) (string, error) {
+	branches := []string{c.Branch}
+	if len(c.Default) != 0 && c.Default != c.Branch {
+		branches = append(branches, c.Default)
+	}
+	for _, branch := range branches {
+		dir := filepath.Join(c.dir(branch), escapeName(key))
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	for _, prefix := range fallback {
+		if len(prefix) == 0 {
+			continue
+		}
+		for _, branch := range branches {
+			if match := latest(c.dir(branch), escapeName(prefix)); len(match) != 0 {
+				return match, nil
+			}
+		}
+	}
+	return "", ErrKeyMiss
+}
+
+// dir is a helper function that returns the directory
+// storing the cache keys of the branch.
+func (c *Cache) dir(branch string) string {
+	return filepath.Join(c.Root, keysDir, c.Repo, escapeName(branch))
+}
+
+// PluginDir returns the directory, relative to the root,
+// mounted by the cache plugins for the repository branch.
+func PluginDir(repo, branch string) string {
+	return path.Join(branchesDir, repo, escapeName(branch))
+}
+
+// PluginFallback returns the directory, relative to the root,
+// from which the cache plugins restore the cache of the
+// repository branch. If the branch has no cache, the cache of
+// the default branch is used, else the repository directory
+// used by cache plugins before caches were stored per branch.
+func PluginFallback(root, repo, branch, defaultBranch string) string {
+	dirs := []string{PluginDir(repo, branch)}
+	if len(defaultBranch) != 0 && defaultBranch != branch {
+		dirs = append(dirs, PluginDir(repo, defaultBranch))
+	}
+	dirs = append(dirs, repo)
+	for _, dir := range dirs {
+		infos, err := ioutil.ReadDir(filepath.Join(root, dir))
+		if err == nil && len(infos) != 0 {
+			return dir
+		}
+	}
+	return dirs[0]
+}
+
+// clean is a helper function that returns the cleaned
+// path, or an error if the path is not relative to the
+// workspace.
+func clean(p string) (string, error) {
+	p = path.Clean(p)
+	if path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrPath
+	}
+	return p, nil
+}
+
+// latest is a helper function that returns the most recently
+// saved key directory with the named prefix.
+func latest(dir, prefix string) string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var match string
+	var saved time.Time
+	for _, info := range infos {
+		if !info.IsDir() || !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		// keys saved without the marker file fall back to the
+		// directory modification time.
+		t := info.ModTime()
+		if marker, err := os.Stat(filepath.Join(dir, info.Name(), savedFile)); err == nil {
+			t = marker.ModTime()
+		}
+		if len(match) == 0 || t.After(saved) {
+			match, saved = info.Name(), t
+		}
+	}
+	if len(match) == 0 {
+		return ""
+	}
+	return filepath.Join(dir, match)
+}
+
+var unsafeName = regexp.MustCompile("[^a-zA-Z0-9._-]")
+
+// escapeName is a helper function that replaces characters
+// that are not safe for use in a file name.
+func escapeName(name string) string {
+	name = unsafeName.ReplaceAllString(name, "_")
+	return strings.TrimLeft(name, ".")
+}
+
+// escape is a helper function that returns the archive
+// file name for the path.
+func escape(p string) string {
+	return url.QueryEscape(p) + ".tar"
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Cache(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Workspace cache", func() {
+
+		var root string
+		var vol *volume
+
+		g.BeforeEach(func() {
+			root, _ = ioutil.TempDir("", "drone-cache-")
+			vol = &volume{files: map[string]string{}, restored: map[string]string{}, truncated: map[string]bool{}}
+			vol.files["/drone/src/go.sum"] = "github.com/franela/goblin v0.0.1"
+			vol.files["/drone/src/vendor"] = "vendored files"
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(root)
+		})
+
+		g.It("Should render the cache key checksum", func() {
+			key, err := Key(vol, "/drone/src", `deps-{{ checksum "go.sum" }}`, &KeyData{})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal(fmt.Sprintf("deps-%x", sha256.Sum256([]byte(vol.files["/drone/src/go.sum"]))))
+		})
+
+		g.It("Should render the cache key build metadata", func() {
+			key, err := Key(vol, "/drone/src", `{{ .Branch }}-deps`, &KeyData{Branch: "master"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal("master-deps")
+		})
+
+		g.It("Should error when the checksum file is missing", func() {
+			_, err := Key(vol, "/drone/src", `deps-{{ checksum "Gemfile.lock" }}`, &KeyData{})
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should save and restore paths", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			err := c.Save("deps-1", []string{"vendor", "node_modules"})
+			g.Assert(err == nil).IsTrue()
+
+			key, err := c.Restore("deps-1", nil, []string{"vendor", "node_modules"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal("deps-1")
+			g.Assert(vol.restored["/drone/src"]).Equal("vendored files")
+		})
+
+		g.It("Should restore a fallback key", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			c.Save("deps-1", []string{"vendor"})
+
+			key, err := c.Restore("deps-2", []string{"deps-"}, []string{"vendor"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal("deps-1")
+		})
+
+		g.It("Should restore the default branch key", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			c.Save("deps-1", []string{"vendor"})
+
+			c.Branch = "feature/foo"
+			c.Default = "master"
+			key, err := c.Restore("deps-1", nil, []string{"vendor"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal("deps-1")
+		})
+
+		g.It("Should restore the most recently saved fallback key", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			c.Save("deps-1", []string{"vendor"})
+			c.Save("deps-2", []string{"vendor"})
+			saved := time.Now().Add(-time.Hour)
+			os.Chtimes(filepath.Join(c.dir("master"), "deps-1", savedFile), saved, saved)
+
+			// restoring a key does not change the save order.
+			c.Restore("deps-1", nil, []string{"vendor"})
+			key, err := c.Restore("deps-3", []string{"deps-"}, []string{"vendor"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(key).Equal("deps-2")
+		})
+
+		g.It("Should error when the archive fails", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			vol.truncated["/drone/src/vendor"] = true
+			g.Assert(c.Save("deps-1", []string{"vendor"}) != nil).IsTrue()
+
+			_, err := c.Restore("deps-1", nil, []string{"vendor"})
+			g.Assert(err).Equal(ErrKeyMiss)
+		})
+
+		g.It("Should skip paths that do not exist", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			g.Assert(c.Save("deps-1", []string{"node_modules"}) == nil).IsTrue()
+		})
+
+		g.It("Should error when the key is not found", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			_, err := c.Restore("deps-1", []string{"deps-"}, []string{"vendor"})
+			g.Assert(err).Equal(ErrKeyMiss)
+		})
+
+		g.It("Should use the plugin cache of the branch", func() {
+			os.MkdirAll(filepath.Join(root, ".branches/octocat/hello-world/feature_foo/node_modules"), 0700)
+			os.MkdirAll(filepath.Join(root, ".branches/octocat/hello-world/master/node_modules"), 0700)
+			dir := PluginFallback(root, "octocat/hello-world", "feature/foo", "master")
+			g.Assert(dir).Equal(".branches/octocat/hello-world/feature_foo")
+		})
+
+		g.It("Should fall back to the plugin cache of the default branch", func() {
+			os.MkdirAll(filepath.Join(root, ".branches/octocat/hello-world/feature_foo"), 0700)
+			os.MkdirAll(filepath.Join(root, ".branches/octocat/hello-world/master/node_modules"), 0700)
+			dir := PluginFallback(root, "octocat/hello-world", "feature/foo", "master")
+			g.Assert(dir).Equal(".branches/octocat/hello-world/master")
+		})
+
+		g.It("Should fall back to the repository plugin cache", func() {
+			os.MkdirAll(filepath.Join(root, "octocat/hello-world/node_modules"), 0700)
+			dir := PluginFallback(root, "octocat/hello-world", "feature/foo", "master")
+			g.Assert(dir).Equal("octocat/hello-world")
+
+			dir = PluginFallback(root, "octocat/spoon-knife", "feature/foo", "master")
+			g.Assert(dir).Equal(".branches/octocat/spoon-knife/feature_foo")
+		})
+
+		g.It("Should not fall back to the plugin cache of other branches", func() {
+			os.MkdirAll(filepath.Join(root, ".branches/octocat/hello-world/develop/node_modules"), 0700)
+			dir := PluginFallback(root, "octocat/hello-world", "feature/foo", "master")
+			g.Assert(dir).Equal(".branches/octocat/hello-world/feature_foo")
+		})
+
+		g.It("Should reject paths outside the workspace", func() {
+			c := &Cache{Root: root, Repo: "octocat/hello-world", Branch: "master", Workspace: "/drone/src", Volume: vol}
+			g.Assert(c.Save("deps-1", []string{"../../etc"})).Equal(ErrPath)
+			g.Assert(c.Save("deps-1", []string{"/etc"})).Equal(ErrPath)
+		})
+	})
+}
+
+// volume is a fake build volume that stores a single
+// file per path, and records the restored archives.
+// Archives of truncated paths are cut short.
+type volume struct {
+	files     map[string]string
+	restored  map[string]string
+	truncated map[string]bool
+}
+
+func (v *volume) CopyFrom(p string) (io.ReadCloser, error) {
+	data, ok := v.files[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{
+		Name:     path.Base(p),
+		Mode:     0644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	})
+	tw.Write([]byte(data))
+	tw.Close()
+	if v.truncated[p] {
+		buf.Truncate(520)
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+func (v *volume) CopyTo(p string, r io.Reader) error {
+	var buf bytes.Buffer
+	err := copyTar(&buf, r)
+	v.restored[p] = buf.String()
+	return err
+}
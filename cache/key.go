@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path"
+	"text/template"
+)
+
+// KeyData defines the build metadata available when
+// rendering a cache key template.
+type KeyData struct {
+	Branch string
+	Commit string
+	Event  string
+}
+
+// Key renders the cache key template. The template may use
+// the checksum function to include the sha256 checksum of
+// one or more files in the workspace, for example:
+//
+//	deps-{{ checksum "go.sum" }}
+func Key(v Volume, workspace, key string, data *KeyData) (string, error) {
+	funcs := template.FuncMap{
+		"checksum": func(files ...string) (string, error) {
+			return checksum(v, workspace, files...)
+		},
+	}
+	tmpl, err := template.New("_").Funcs(funcs).Parse(key)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
+// checksum is a helper function that returns the sha256
+// checksum of the contents of the workspace files.
+func checksum(v Volume, workspace string, files ...string) (string, error) {
+	h := sha256.New()
+	for _, file := range files {
+		file, err := clean(file)
+		if err != nil {
+			return "", err
+		}
+		rc, err := v.CopyFrom(path.Join(workspace, file))
+		if err != nil {
+			return "", fmt.Errorf("Error reading %s. %s", file, err)
+		}
+		err = copyTar(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", fmt.Errorf("Error reading %s. %s", file, err)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// copyTar is a helper function that copies the contents
+// of the regular files in the tar archive to w.
+func copyTar(w io.Writer, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		_, err = io.Copy(w, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
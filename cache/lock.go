@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockSuffix is the suffix of the lock file of a cached
+// directory, which is stored next to the directory so that
+// the lock file is not part of the cached files.
+const lockSuffix = ".lock"
+
+// Lock holds a shared lock on the cached directory while the
+// directory is in use by a build, for example while mounted
+// by the cache plugins, so that the directory is not evicted.
+// The lock is released by closing the returned lock.
+func Lock(path string) (io.Closer, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// tryLock is a helper function that attempts to hold an
+// exclusive lock on the cached directory, and returns false
+// if the directory is locked by a build.
+func tryLock(path string) (io.Closer, bool, error) {
+	f, err := os.OpenFile(path+lockSuffix, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, false, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		f.Close()
+		return nil, false, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return f, true, nil
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry represents a cached directory on the host machine.
+type Entry struct {
+	Repo   string    // repository full name
+	Branch string    // branch namespace, empty for the repository cache
+	Key    string    // cache key, empty for the plugin cache
+	Path   string    // absolute path of the directory
+	Size   int64     // size in bytes
+	Access time.Time // last access time
+}
+
+// Manager tracks the size and last access time of the
+// cached directories on the host machine, and enforces
+// the cache quotas by evicting the least recently used
+// directories.
+type Manager struct {
+	Root      string // host directory storing the cache
+	Quota     int64  // global quota in bytes, zero if unlimited
+	RepoQuota int64  // per-repository quota in bytes, zero if unlimited
+}
+
+// List returns the cached directories, ordered from the
+// least recently used to the most recently used. This
+// includes the directories mounted by cache plugins as
+// well as the native cache keys.
+func (m *Manager) List() ([]*Entry, error) {
+	var entries []*Entry
+
+	// cache plugin directories are stored by repository
+	// in the root directory, before caches were stored per
+	// branch, and by repository and branch in the branches
+	// directory.
+	dirs, err := glob(m.Root, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		entry, err := newEntry(m.Root, dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	root := filepath.Join(m.Root, branchesDir)
+	dirs, err = glob(root, 3)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		entry, err := newEntry(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	// native cache keys are stored by repository, branch
+	// and key in the keys directory.
+	root = filepath.Join(m.Root, keysDir)
+	dirs, err = glob(root, 4)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		entry, err := newEntry(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Sort(byAccess(entries))
+	return entries, nil
+}
+
+// Prune evicts the least recently used directories until
+// the per-repository and global quotas are met, and returns
+// the evicted directories. Directories locked by a build are
+// not evicted.
+func (m *Manager) Prune() ([]*Entry, error) {
+	entries, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	var repos = map[string]int64{}
+	for _, entry := range entries {
+		total += entry.Size
+		repos[entry.Repo] += entry.Size
+	}
+
+	var evicted []*Entry
+	for _, entry := range entries {
+		switch {
+		case m.RepoQuota > 0 && repos[entry.Repo] > m.RepoQuota:
+		case m.Quota > 0 && total > m.Quota:
+		default:
+			continue
+		}
+		lock, ok, err := tryLock(entry.Path)
+		if err != nil {
+			return evicted, err
+		}
+		if !ok {
+			continue
+		}
+		err = os.RemoveAll(entry.Path)
+		lock.Close()
+		if err != nil {
+			return evicted, err
+		}
+		total -= entry.Size
+		repos[entry.Repo] -= entry.Size
+		evicted = append(evicted, entry)
+	}
+	return evicted, nil
+}
+
+// Touch records the access time of the cached directory.
+func Touch(path string) error {
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// ParseSize parses a human readable size, such as 512MB
+// or 10GB, and returns the size in bytes.
+func ParseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1e12},
+		{"GB", 1e9},
+		{"MB", 1e6},
+		{"KB", 1e3},
+		{"B", 1},
+	}
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range units {
+		if !strings.HasSuffix(s, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, unit.suffix), 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid size %s", s)
+		}
+		return int64(n * float64(unit.size)), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// newEntry is a helper function that returns the cache
+// entry for the directory, relative to the root.
+func newEntry(root, dir string) (*Entry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	size, err := du(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, _ := filepath.Rel(root, dir)
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 4)
+	entry := &Entry{
+		Repo:   strings.Join(parts[:2], "/"),
+		Path:   dir,
+		Size:   size,
+		Access: info.ModTime(),
+	}
+	if len(parts) > 2 {
+		entry.Branch = parts[2]
+	}
+	if len(parts) > 3 {
+		entry.Key = parts[3]
+	}
+	return entry, nil
+}
+
+// glob is a helper function that returns the directories
+// at the specified depth below the root, skipping hidden
+// directories.
+func glob(root string, depth int) ([]string, error) {
+	dirs := []string{root}
+	for i := 0; i < depth; i++ {
+		var next []string
+		for _, dir := range dirs {
+			infos, err := ioutil.ReadDir(dir)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range infos {
+				if !info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+					continue
+				}
+				next = append(next, filepath.Join(dir, info.Name()))
+			}
+		}
+		dirs = next
+	}
+	return dirs, nil
+}
+
+// du is a helper function that returns the total size of
+// the files in the directory.
+func du(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+type byAccess []*Entry
+
+func (e byAccess) Len() int           { return len(e) }
+func (e byAccess) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byAccess) Less(i, j int) bool { return e[i].Access.Before(e[j].Access) }
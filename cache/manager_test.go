@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Manager(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Cache manager", func() {
+
+		var root string
+
+		// write is a helper function that creates a cache
+		// directory with a file of the specified size, last
+		// accessed the specified number of hours ago.
+		write := func(rel string, size int, hours int) {
+			dir := filepath.Join(root, rel)
+			os.MkdirAll(dir, 0700)
+			ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, size), 0600)
+			when := time.Now().Add(time.Duration(-hours) * time.Hour)
+			os.Chtimes(dir, when, when)
+		}
+
+		g.BeforeEach(func() {
+			root, _ = ioutil.TempDir("", "drone-cache-")
+			write(".branches/octocat/hello-world/master", 100, 3)
+			write(".keys/octocat/hello-world/master/deps-1", 200, 2)
+			write(".branches/octocat/spoon-knife/master", 300, 1)
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(root)
+		})
+
+		g.It("Should list entries by last access", func() {
+			m := &Manager{Root: root}
+			entries, err := m.List()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(entries)).Equal(3)
+			g.Assert(entries[0].Repo).Equal("octocat/hello-world")
+			g.Assert(entries[0].Branch).Equal("master")
+			g.Assert(entries[0].Key).Equal("")
+			g.Assert(entries[0].Size).Equal(int64(100))
+			g.Assert(entries[1].Repo).Equal("octocat/hello-world")
+			g.Assert(entries[1].Branch).Equal("master")
+			g.Assert(entries[1].Key).Equal("deps-1")
+			g.Assert(entries[2].Repo).Equal("octocat/spoon-knife")
+		})
+
+		g.It("Should list the repository plugin cache", func() {
+			write("octocat/hello-world", 400, 4)
+			m := &Manager{Root: root}
+			entries, err := m.List()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(entries)).Equal(4)
+			g.Assert(entries[0].Repo).Equal("octocat/hello-world")
+			g.Assert(entries[0].Branch).Equal("")
+			g.Assert(entries[0].Size).Equal(int64(400))
+		})
+
+		g.It("Should evict to the global quota", func() {
+			m := &Manager{Root: root, Quota: 500}
+			evicted, err := m.Prune()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(evicted)).Equal(1)
+			g.Assert(evicted[0].Key).Equal("")
+
+			entries, _ := m.List()
+			g.Assert(len(entries)).Equal(2)
+		})
+
+		g.It("Should not evict locked directories", func() {
+			lock, err := Lock(filepath.Join(root, ".branches/octocat/hello-world/master"))
+			g.Assert(err == nil).IsTrue()
+			defer lock.Close()
+
+			m := &Manager{Root: root, Quota: 500}
+			evicted, err := m.Prune()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(evicted)).Equal(1)
+			g.Assert(evicted[0].Key).Equal("deps-1")
+
+			_, err = os.Stat(filepath.Join(root, ".branches/octocat/hello-world/master"))
+			g.Assert(err == nil).IsTrue()
+		})
+
+		g.It("Should evict to the repository quota", func() {
+			m := &Manager{Root: root, RepoQuota: 250}
+			evicted, err := m.Prune()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(evicted)).Equal(2)
+			g.Assert(evicted[0].Repo).Equal("octocat/hello-world")
+			g.Assert(evicted[1].Repo).Equal("octocat/spoon-knife")
+		})
+
+		g.It("Should not evict without a quota", func() {
+			m := &Manager{Root: root}
+			evicted, err := m.Prune()
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(evicted)).Equal(0)
+		})
+
+		g.It("Should parse sizes", func() {
+			size, _ := ParseSize("10GB")
+			g.Assert(size).Equal(int64(10e9))
+			size, _ = ParseSize("512mb")
+			g.Assert(size).Equal(int64(512e6))
+			size, _ = ParseSize("1024")
+			g.Assert(size).Equal(int64(1024))
+			_, err := ParseSize("ten GB")
+			g.Assert(err != nil).IsTrue()
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	wcache "github.com/drone/drone-exec/cache"
+)
+
+// cacheCommand executes the cache subcommands used to
+// inspect and prune the cache on the host machine:
+//
+//	drone-exec cache ls
+//	drone-exec cache prune --cache-quota=10GB
+func cacheCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: drone-exec cache [ls|prune] [flags]")
+		return 2
+	}
+
+	var quota, repoQuota string
+	flags := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	flags.StringVar(&dir, "cache-dir", wcache.DefaultRoot, "")
+	flags.StringVar(&quota, "cache-quota", "", "")
+	flags.StringVar(&repoQuota, "cache-repo-quota", "", "")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	m, err := newManager(dir, quota, repoQuota)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch args[0] {
+	case "ls":
+		entries, err := m.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error listing the cache.", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "REPO\tBRANCH\tKEY\tSIZE\tLAST ACCESS")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				entry.Repo,
+				entry.Branch,
+				entry.Key,
+				humanSize(entry.Size),
+				entry.Access.Format(time.RFC3339),
+			)
+		}
+		w.Flush()
+
+	case "prune":
+		if m.Quota == 0 && m.RepoQuota == 0 {
+			fmt.Fprintln(os.Stderr, "Prune requires --cache-quota or --cache-repo-quota")
+			return 2
+		}
+		evicted, err := m.Prune()
+		for _, entry := range evicted {
+			fmt.Printf("Evicted %s (%s)\n", entry.Path, humanSize(entry.Size))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error pruning the cache.", err)
+			return 1
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cache command %s\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+// newManager is a helper function that returns the cache
+// manager for the host directory and human readable quotas.
+func newManager(root, quota, repoQuota string) (*wcache.Manager, error) {
+	m := &wcache.Manager{Root: root}
+	if len(quota) != 0 {
+		size, err := wcache.ParseSize(quota)
+		if err != nil {
+			return nil, err
+		}
+		m.Quota = size
+	}
+	if len(repoQuota) != 0 {
+		size, err := wcache.ParseSize(repoQuota)
+		if err != nil {
+			return nil, err
+		}
+		m.RepoQuota = size
+	}
+	return m, nil
+}
+
+// humanSize is a helper function that returns a human
+// readable representation of the byte size.
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	i, f := 0, float64(size)
+	for f >= 1000 && i < len(units)-1 {
+		f = f / 1000
+		i++
+	}
+	return fmt.Sprintf("%.4g %s", f, units[i])
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/drone/drone-exec/yaml/secure"
+	"github.com/drone/drone-exec/yaml/shasum"
+	"gopkg.in/yaml.v2"
+)
+
+// secureCommand executes the secure subcommands used to
+// encrypt, verify and rotate the secrets file, and to sign
+// the yaml file:
+//
+//	drone-exec secure encrypt --key=id_rsa.pub --in=secrets.yml --yaml=.drone.yml --repo=octocat/hello-world --expires=720h
+//	drone-exec secure verify --key=id_rsa --in=.drone.sec --yaml=.drone.yml --repo=octocat/hello-world
+//
+// The encrypt command requires the secrets are bound to the
+// repository, unless --allow-any-repo is given. The verify
+// command reports the repository bound to the secrets when
+// no repository is given.
+//
+//	drone-exec secure rotate --key=id_rsa --new-key=id_rsa_new.pub --in=.drone.sec
+//	drone-exec secure sign --key=id_rsa --yaml=.drone.yml --out=.drone.yml.sig
+func secureCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: drone-exec secure [encrypt|verify|rotate|sign] [flags]")
+		return 2
+	}
+
+	var key, newKey, in, conf, out, repo string
+	var expires time.Duration
+	var anyRepo bool
+	flags := flag.NewFlagSet("secure "+args[0], flag.ContinueOnError)
+	flags.StringVar(&key, "key", "", "")
+	flags.StringVar(&newKey, "new-key", "", "")
+	flags.StringVar(&in, "in", "", "")
+	flags.StringVar(&conf, "yaml", ".drone.yml", "")
+	flags.StringVar(&out, "out", "", "")
+	flags.StringVar(&repo, "repo", "", "")
+	flags.DurationVar(&expires, "expires", 0, "")
+	flags.BoolVar(&anyRepo, "allow-any-repo", false, "")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if len(key) == 0 || (len(in) == 0 && args[0] != "sign") {
+		fmt.Fprintln(os.Stderr, "Secure commands require --key and --in")
+		return 2
+	}
+
+	keyData, err := ioutil.ReadFile(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading the key.", err)
+		return 1
+	}
+
+	// the sign command signs the yaml file, and does not
+	// require the secrets file.
+	if args[0] == "sign" {
+		raw, err := ioutil.ReadFile(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading the .drone.yml.", err)
+			return 1
+		}
+		sig, err := secure.Sign(string(raw), string(keyData))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error signing the .drone.yml.", err)
+			return 1
+		}
+		return writeFile([]byte(sig), out)
+	}
+
+	// the input file is the plaintext secrets for the encrypt
+	// command, and the encrypted secrets otherwise.
+	inData, err := ioutil.ReadFile(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading the secrets.", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "encrypt":
+		if len(repo) == 0 && !anyRepo {
+			fmt.Fprintln(os.Stderr, "Encrypt requires --repo or --allow-any-repo")
+			return 2
+		}
+		raw, err := ioutil.ReadFile(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading the .drone.yml.", err)
+			return 1
+		}
+		plain, err := secure.SetChecksum(inData, shasum.Sum(string(raw)))
+		if err == nil && len(repo) != 0 {
+			plain, err = secure.SetClaim(plain, "repo", repo)
+		}
+		if err == nil && expires != 0 {
+			plain, err = secure.SetClaim(plain, "exp", time.Now().Add(expires).Unix())
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error parsing the secrets.", err)
+			return 1
+		}
+		return writeSecrets(plain, keyData, out)
+
+	case "verify":
+		raw, err := ioutil.ReadFile(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading the .drone.yml.", err)
+			return 1
+		}
+		plain, err := secure.Decrypt(string(inData), string(keyData))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error decrypting the secrets.", err)
+			return 1
+		}
+		sec := &secure.Secure{}
+		err = yaml.Unmarshal(plain, sec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error parsing the secrets.", err)
+			return 1
+		}
+
+		// the repository is read from the claim when not
+		// given, so that bound secrets can be verified.
+		if len(repo) == 0 {
+			repo = sec.Repo
+		}
+		err = sec.Validate(repo)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if len(sec.Repo) == 0 {
+			fmt.Println(secure.ErrUnbound)
+		} else {
+			fmt.Printf("Secrets are encrypted for repository %s\n", sec.Repo)
+		}
+		if len(sec.Checksum) == 0 {
+			fmt.Println("Secrets do not include a checksum")
+			return 1
+		}
+		if !shasum.Check(string(raw), sec.Checksum) {
+			fmt.Printf("Secrets checksum does not match %s\n", conf)
+			return 1
+		}
+		fmt.Printf("Secrets checksum matches %s\n", conf)
+
+	case "rotate":
+		if len(newKey) == 0 {
+			fmt.Fprintln(os.Stderr, "Rotate requires --new-key")
+			return 2
+		}
+		newKeyData, err := ioutil.ReadFile(newKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading the key.", err)
+			return 1
+		}
+		plain, err := secure.Decrypt(string(inData), string(keyData))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error decrypting the secrets.", err)
+			return 1
+		}
+		return writeSecrets(plain, newKeyData, out)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown secure command %s\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+// writeSecrets is a helper function that encrypts the secrets
+// with the public key, and writes the result to the named file
+// or to stdout if no file is named.
+func writeSecrets(plain, pubKey []byte, name string) int {
+	encrypted, err := secure.Encrypt(string(plain), string(pubKey))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error encrypting the secrets.", err)
+		return 1
+	}
+	return writeFile([]byte(encrypted), name)
+}
+
+// writeFile is a helper function that writes the data to the
+// named file, or to stdout if no file is named.
+func writeFile(data []byte, name string) int {
+	if len(name) == 0 {
+		fmt.Println(string(data))
+		return 0
+	}
+	err := ioutil.WriteFile(name, data, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing the file.", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/samalba/dockerclient"
+)
+
+// archiveVersion is the minimum Docker API version that
+// supports the container archive endpoints.
+const archiveVersion = "v1.20"
+
+var ErrArchive = errors.New("Archive not supported by the Docker client")
+
+// CopyFromContainer returns a tar archive of the file or
+// directory at the path in the container filesystem. If the
+// path does not exist the error is os.ErrNotExist.
+func CopyFromContainer(client *dockerclient.DockerClient, id, path string) (io.ReadCloser, error) {
+	v := url.Values{}
+	v.Set("path", path)
+	uri := fmt.Sprintf("%s/%s/containers/%s/archive?%s", client.URL, archiveVersion, id, v.Encode())
+	resp, err := client.HTTPClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(resp)
+	if err == dockerclient.ErrNotFound {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CopyToContainer extracts a tar archive into the directory
+// at the path in the container filesystem.
+func CopyToContainer(client *dockerclient.DockerClient, id, path string, r io.Reader) error {
+	v := url.Values{}
+	v.Set("path", path)
+	uri := fmt.Sprintf("%s/%s/containers/%s/archive?%s", client.URL, archiveVersion, id, v.Encode())
+	req, err := http.NewRequest("PUT", uri, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// checkResponse is a helper function that returns an error
+// if the Docker API response status indicates a failure.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == 404 {
+		return dockerclient.ErrNotFound
+	}
+	if resp.StatusCode >= 400 {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%s", data)
+	}
+	return nil
+}
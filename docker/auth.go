@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+// DefaultRegistry is the hostname of the default
+// Docker Hub registry.
+const DefaultRegistry = "index.docker.io"
+
+// Auth defines the credentials used to authenticate
+// image pulls from a private registry.
+type Auth struct {
+	Registry string `json:"registry"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+// FindAuth returns the credentials for the registry that
+// hosts the named image. If no credentials match the
+// registry hostname a nil value is returned.
+func FindAuth(auths []*Auth, image string) *dockerclient.AuthConfig {
+	host := Hostname(image)
+	for _, auth := range auths {
+		if auth == nil || normalizeHost(auth.Registry) != host {
+			continue
+		}
+		return &dockerclient.AuthConfig{
+			Username: auth.Username,
+			Password: auth.Password,
+			Email:    auth.Email,
+		}
+	}
+	return nil
+}
+
+// Hostname returns the hostname of the registry that
+// hosts the named image. Images without a registry
+// hostname are hosted in the default registry.
+func Hostname(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return DefaultRegistry
+	}
+	host := parts[0]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return DefaultRegistry
+	}
+	return normalizeHost(host)
+}
+
+// ParseAuth parses the registry credentials from a Docker
+// config.json file. The legacy .dockercfg format, which
+// omits the top-level auths section, is also supported.
+func ParseAuth(in []byte) ([]*Auth, error) {
+	type entry struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+	conf := struct {
+		Auths map[string]entry `json:"auths"`
+	}{}
+	err := json.Unmarshal(in, &conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Auths == nil {
+		err = json.Unmarshal(in, &conf.Auths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var auths []*Auth
+	for host, e := range conf.Auths {
+		auth := &Auth{
+			Registry: host,
+			Username: e.Username,
+			Password: e.Password,
+			Email:    e.Email,
+		}
+		if len(e.Auth) != 0 {
+			decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+			if err != nil {
+				return nil, err
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				auth.Username = parts[0]
+				auth.Password = parts[1]
+			}
+		}
+		auths = append(auths, auth)
+	}
+	return auths, nil
+}
+
+// ParseAuthFile parses the registry credentials from the
+// Docker config.json file at the specified path.
+func ParseAuthFile(path string) ([]*Auth, error) {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAuth(in)
+}
+
+// AuthFilePath returns the default path of the host
+// Docker config.json file.
+func AuthFilePath() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if len(dir) == 0 {
+		dir = filepath.Join(os.Getenv("HOME"), ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// normalizeHost is a helper function that strips the scheme
+// and path from a registry address, and maps the aliases of
+// the default registry to a single hostname.
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i != -1 {
+		host = host[:i]
+	}
+	switch host {
+	case "docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		host = DefaultRegistry
+	}
+	return host
+}
@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Auth(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Registry auth", func() {
+
+		g.It("Should resolve the default registry hostname", func() {
+			g.Assert(Hostname("golang:1.5")).Equal("index.docker.io")
+			g.Assert(Hostname("plugins/drone-git:latest")).Equal("index.docker.io")
+		})
+
+		g.It("Should resolve a private registry hostname", func() {
+			g.Assert(Hostname("registry.example.com/foo/bar:latest")).Equal("registry.example.com")
+			g.Assert(Hostname("localhost:5000/foo:latest")).Equal("localhost:5000")
+			g.Assert(Hostname("localhost/foo:latest")).Equal("localhost")
+		})
+
+		g.It("Should find credentials matching the hostname", func() {
+			auths := []*Auth{
+				{Registry: "https://index.docker.io/v1/", Username: "octocat"},
+				{Registry: "registry.example.com", Username: "janedoe"},
+			}
+			g.Assert(FindAuth(auths, "golang:1.5").Username).Equal("octocat")
+			g.Assert(FindAuth(auths, "registry.example.com/foo:latest").Username).Equal("janedoe")
+			g.Assert(FindAuth(auths, "quay.io/foo:latest") == nil).IsTrue()
+		})
+
+		g.It("Should parse a docker config.json file", func() {
+			auths, err := ParseAuth([]byte(configJson))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(auths)).Equal(1)
+			g.Assert(auths[0].Registry).Equal("registry.example.com")
+			g.Assert(auths[0].Username).Equal("octocat")
+			g.Assert(auths[0].Password).Equal("password")
+			g.Assert(auths[0].Email).Equal("octocat@github.com")
+		})
+
+		g.It("Should parse a legacy dockercfg file", func() {
+			auths, err := ParseAuth([]byte(dockercfg))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(auths)).Equal(1)
+			g.Assert(auths[0].Registry).Equal("registry.example.com")
+			g.Assert(auths[0].Username).Equal("octocat")
+			g.Assert(auths[0].Password).Equal("password")
+		})
+	})
+}
+
+var configJson = `{
+	"auths": {
+		"registry.example.com": {
+			"auth": "b2N0b2NhdDpwYXNzd29yZA==",
+			"email": "octocat@github.com"
+		}
+	}
+}`
+
+var dockercfg = `{
+	"registry.example.com": {
+		"auth": "b2N0b2NhdDpwYXNzd29yZA==",
+		"email": "octocat@github.com"
+	}
+}`
@@ -0,0 +1,182 @@
+package docker
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/samalba/dockerclient"
+)
+
+// fakeDaemon is a fake Docker daemon, serving the subset of the
+// remote API used to run containers.
+type fakeDaemon struct {
+	sync.Mutex
+	server *httptest.Server
+
+	images     map[string]string // image references mapped to image ids
+	digests    map[string]string // image ids mapped to repository digests
+	containers map[string]*fakeContainer
+	volumes    map[string]map[string]string // volume names mapped to volume files
+	created    int
+}
+
+// fakeContainer is a container created by the fake daemon.
+type fakeContainer struct {
+	Id      string
+	Config  *dockerclient.ContainerConfig
+	Running bool
+	Layer   map[string]string // files written to the container layer
+}
+
+// newFakeDaemon starts a fake Docker daemon and returns the
+// daemon with a Docker client connected to it.
+func newFakeDaemon() (*fakeDaemon, *dockerclient.DockerClient) {
+	d := &fakeDaemon{
+		images:     map[string]string{},
+		digests:    map[string]string{},
+		containers: map[string]*fakeContainer{},
+		volumes:    map[string]map[string]string{},
+	}
+	d.server = httptest.NewServer(d)
+	client, _ := dockerclient.NewDockerClient(d.server.URL, nil)
+	return d, client
+}
+
+// tag adds the image to the fake daemon, tagged with the image
+// reference and pulled by the repository digest.
+func (d *fakeDaemon) tag(image, id, digest string) {
+	d.images[image] = id
+	d.images[digest] = id
+	d.digests[id] = digest
+}
+
+// mounted returns the volume bound to the running container
+// at the file path, along with the path relative to the volume.
+func (d *fakeDaemon) mounted(c *fakeContainer, file string) (string, string, bool) {
+	if !c.Running {
+		return "", "", false
+	}
+	for _, bind := range c.Config.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if strings.HasPrefix(file, parts[1]+"/") {
+			return parts[0], strings.TrimPrefix(file, parts[1]+"/"), true
+		}
+	}
+	return "", "", false
+}
+
+// unmount discards the files of in-memory volumes that are not
+// bound to a running container.
+func (d *fakeDaemon) unmount() {
+	for name := range d.volumes {
+		var held bool
+		for _, c := range d.containers {
+			for _, bind := range c.Config.HostConfig.Binds {
+				held = held || (c.Running && strings.HasPrefix(bind, name+":"))
+			}
+		}
+		if !held {
+			d.volumes[name] = map[string]string{}
+		}
+	}
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Lock()
+	defer d.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/"+dockerclient.APIVersion)
+	path = strings.TrimPrefix(path, "/"+archiveVersion)
+	switch {
+	case r.Method == "GET" && path == "/images/json":
+		var images []*dockerclient.Image
+		for id, digest := range d.digests {
+			images = append(images, &dockerclient.Image{Id: id, RepoDigests: []string{digest}})
+		}
+		json.NewEncoder(w).Encode(images)
+
+	case r.Method == "GET" && strings.HasPrefix(path, "/images/"):
+		id, ok := d.images[strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(&dockerclient.ImageInfo{Id: id})
+
+	case r.Method == "POST" && path == "/containers/create":
+		c := &fakeContainer{Id: fmt.Sprintf("container%d", d.created), Layer: map[string]string{}}
+		json.NewDecoder(r.Body).Decode(&c.Config)
+		d.created++
+		d.containers[c.Id] = c
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"Id":%q}`, c.Id)
+
+	case strings.HasPrefix(path, "/containers/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/containers/"), "/", 2)
+		c, ok := d.containers[parts[0]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case r.Method == "DELETE":
+			delete(d.containers, c.Id)
+			d.unmount()
+			w.WriteHeader(204)
+		case len(parts) == 1:
+			http.NotFound(w, r)
+		case parts[1] == "json":
+			json.NewEncoder(w).Encode(&dockerclient.ContainerInfo{
+				Id:         c.Id,
+				Config:     c.Config,
+				HostConfig: &c.Config.HostConfig,
+				State:      &dockerclient.State{Running: c.Running},
+			})
+		case parts[1] == "start":
+			c.Running = true
+			w.WriteHeader(204)
+		case parts[1] == "stop", parts[1] == "kill":
+			c.Running = false
+			d.unmount()
+			w.WriteHeader(204)
+		case parts[1] == "archive" && r.Method == "PUT":
+			tr := tar.NewReader(r.Body)
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					break
+				}
+				data, _ := ioutil.ReadAll(tr)
+				file := strings.TrimSuffix(r.URL.Query().Get("path"), "/") + "/" + hdr.Name
+				if vol, name, ok := d.mounted(c, file); ok {
+					d.volumes[vol][name] = string(data)
+				} else {
+					c.Layer[file] = string(data)
+				}
+			}
+			w.WriteHeader(200)
+		default:
+			http.NotFound(w, r)
+		}
+
+	case r.Method == "POST" && path == "/volumes/create":
+		name := fmt.Sprintf("volume%d", d.created)
+		d.volumes[name] = map[string]string{}
+		d.created++
+		w.WriteHeader(201)
+		fmt.Fprintf(w, `{"Name":%q}`, name)
+
+	case r.Method == "DELETE" && strings.HasPrefix(path, "/volumes/"):
+		delete(d.volumes, strings.TrimPrefix(path, "/volumes/"))
+		w.WriteHeader(204)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
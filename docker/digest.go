@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/samalba/dockerclient"
+)
+
+var ErrNoDigest = errors.New("Image digest not available")
+
+// Digest returns the repository digest reference of the
+// named image, in repository@sha256:digest format. Images
+// that were not pulled from a registry, for example images
+// built locally, do not have a digest.
+func Digest(client dockerclient.Client, image string) (string, error) {
+	if strings.Contains(image, "@") {
+		return image, nil
+	}
+	info, err := client.InspectImage(image)
+	if err != nil {
+		return "", err
+	}
+	images, err := client.ListImages(false)
+	if err != nil {
+		return "", err
+	}
+	repo := repository(image)
+	for _, img := range images {
+		if img.Id != info.Id {
+			continue
+		}
+		for _, digest := range img.RepoDigests {
+			if repository(digest) == repo {
+				return digest, nil
+			}
+		}
+	}
+	return "", ErrNoDigest
+}
+
+// pinnedDigest is a helper function that returns the digest
+// used to pin the named image, if the client has a digest of
+// the same repository for the image.
+func pinnedDigest(client dockerclient.Client, image string) (string, bool) {
+	c, ok := client.(*Client)
+	if !ok {
+		return "", false
+	}
+	c.Lock()
+	digest, ok := c.pinned[image]
+	c.Unlock()
+	if !ok || !strings.Contains(digest, "@") || repository(digest) != repository(image) {
+		return "", false
+	}
+	return digest, true
+}
+
+// recordDigest is a helper function that records the digest
+// of the named image, so that the digest can be reported.
+func recordDigest(client dockerclient.Client, image, digest string) {
+	c, ok := client.(*Client)
+	if !ok {
+		return
+	}
+	c.Lock()
+	if c.digests == nil {
+		c.digests = map[string]string{}
+	}
+	c.digests[image] = digest
+	c.Unlock()
+}
+
+// repository is a helper function that returns the image
+// repository name, without the tag or digest, and without
+// the default registry hostname.
+func repository(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	image = strings.TrimPrefix(image, "docker.io/")
+	return strings.TrimPrefix(image, DefaultRegistry+"/")
+}
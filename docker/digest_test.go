@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/yaml"
+	"github.com/franela/goblin"
+	"github.com/samalba/dockerclient"
+)
+
+func Test_Digest(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Image digest", func() {
+
+		g.It("Should trim the image tag", func() {
+			g.Assert(repository("plugins/drone-git:latest")).Equal("plugins/drone-git")
+			g.Assert(repository("golang")).Equal("golang")
+		})
+
+		g.It("Should trim the image digest", func() {
+			g.Assert(repository("plugins/drone-git@sha256:a3ed95caeb02")).Equal("plugins/drone-git")
+		})
+
+		g.It("Should preserve the registry port", func() {
+			g.Assert(repository("localhost:5000/foo:1.0")).Equal("localhost:5000/foo")
+			g.Assert(repository("localhost:5000/foo")).Equal("localhost:5000/foo")
+		})
+
+		g.It("Should trim the default registry", func() {
+			g.Assert(repository("docker.io/plugins/drone-git:latest")).Equal("plugins/drone-git")
+		})
+
+		g.It("Should run a pinned image by the supplied digest", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag("plugins/drone-git:latest", "newer", "plugins/drone-git@sha256:b4c2")
+			daemon.tag("plugins/drone-git:1.0", "older", "plugins/drone-git@sha256:a3ed")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			client.Pin(map[string]string{"plugins/drone-git:latest": "plugins/drone-git@sha256:a3ed"})
+			conf := &dockerclient.ContainerConfig{Image: "plugins/drone-git:latest"}
+			info, err := Start(client, conf, yaml.PullIfNotPresent, true)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(info.Config.Image).Equal("plugins/drone-git@sha256:a3ed")
+			g.Assert(client.Digests()["plugins/drone-git:latest"]).Equal("plugins/drone-git@sha256:a3ed")
+		})
+
+		g.It("Should report the resolved digest", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag("plugins/drone-git:latest", "newer", "plugins/drone-git@sha256:b4c2")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			conf := &dockerclient.ContainerConfig{Image: "plugins/drone-git:latest"}
+			info, err := Start(client, conf, yaml.PullIfNotPresent, true)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(info.Config.Image).Equal("plugins/drone-git@sha256:b4c2")
+			g.Assert(client.Digests()["plugins/drone-git:latest"]).Equal("plugins/drone-git@sha256:b4c2")
+		})
+
+		g.It("Should ignore a supplied digest of another repository", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag("plugins/drone-git:latest", "newer", "plugins/drone-git@sha256:b4c2")
+			daemon.tag("plugins/drone-evil:latest", "evil", "plugins/drone-evil@sha256:e5e5")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			client.Pin(map[string]string{"plugins/drone-git:latest": "plugins/drone-evil@sha256:e5e5"})
+			conf := &dockerclient.ContainerConfig{Image: "plugins/drone-git:latest"}
+			info, err := Start(client, conf, yaml.PullIfNotPresent, true)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(info.Config.Image).Equal("plugins/drone-git@sha256:b4c2")
+		})
+	})
+}
@@ -1,17 +1,52 @@
 package docker
 
-import "github.com/samalba/dockerclient"
+import (
+	"io"
+	"os"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/samalba/dockerclient"
+)
+
+// ambassador is the image of the ambassador container.
+const ambassador = "gliderlabs/alpine:3.1"
 
 // Client is a wrapper around the default Docker client
 // that tracks all created containers ensures some default
 // configurations are in place.
 type Client struct {
+	sync.Mutex
 	dockerclient.Client
 	info  *dockerclient.ContainerInfo
-	names []string // names of created containers
+	names []string             // names of created containers
+	vols  []string             // names of created volumes
+	auths []*Auth              // registry credentials
+	pulls []*PullInfo          // images pulled by this client
+	calls map[string]*pullCall // in-flight and completed pulls
+
+	pinned  map[string]string // image digests used to pin images
+	digests map[string]string // image digests resolved by this client
+}
+
+// pullCall represents an in-flight or completed image pull.
+type pullCall struct {
+	done chan struct{}
+	err  error
 }
 
-func NewClient(docker dockerclient.Client) (*Client, error) {
+// failed reports whether the pull completed with errors.
+func (p *pullCall) failed() bool {
+	select {
+	case <-p.done:
+		return p.err != nil
+	default:
+		return false
+	}
+}
+
+func NewClient(docker dockerclient.Client, auths []*Auth) (*Client, error) {
 
 	// creates an ambassador container
 	conf := &dockerclient.ContainerConfig{}
@@ -20,15 +55,120 @@ func NewClient(docker dockerclient.Client) (*Client, error) {
 	}
 	conf.Entrypoint = []string{"/bin/sleep"}
 	conf.Cmd = []string{"86400"}
-	conf.Image = "gliderlabs/alpine:3.1"
+	conf.Image = ambassador
 	conf.Volumes = map[string]struct{}{}
 	conf.Volumes["/drone"] = struct{}{}
-	info, err := Start(docker, conf, false)
+	info, err := Start(docker, conf, yaml.PullIfNotPresent, false)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{Client: docker, info: info}, nil
+	return &Client{
+		Client:  docker,
+		info:    info,
+		auths:   auths,
+		calls:   map[string]*pullCall{},
+		digests: map[string]string{},
+	}, nil
+}
+
+// PullImage pulls an image, authenticating with the
+// registry credentials that match the image hostname
+// when no credentials are provided. An image is pulled
+// at most once, and concurrent pulls of the same image
+// wait for the in-flight pull to complete.
+func (c *Client) PullImage(name string, auth *dockerclient.AuthConfig) error {
+	c.Lock()
+	call, ok := c.calls[name]
+	if !ok || call.failed() {
+		call = &pullCall{done: make(chan struct{})}
+		c.calls[name] = call
+		c.Unlock()
+
+		call.err = c.pullImage(name, auth)
+		close(call.done)
+		return call.err
+	}
+	c.Unlock()
+
+	<-call.done
+	return call.err
+}
+
+func (c *Client) pullImage(name string, auth *dockerclient.AuthConfig) error {
+	if auth == nil {
+		auth = FindAuth(c.auths, name)
+	}
+
+	// the progress can only be streamed when using the
+	// default client implementation.
+	client, ok := c.Client.(*dockerclient.DockerClient)
+	if !ok {
+		return c.Client.PullImage(name, auth)
+	}
+	info, err := PullStream(client, name, auth, os.Stdout)
+	if err != nil {
+		return err
+	}
+	log.Printf("Pulled image %s in %.1fs (%s)",
+		name,
+		info.Duration.Seconds(),
+		humanSize(info.Bytes),
+	)
+	c.Lock()
+	c.pulls = append(c.pulls, info)
+	c.Unlock()
+	return nil
+}
+
+// Pulls returns the duration and size of every image
+// pulled by this client.
+func (c *Client) Pulls() []*PullInfo {
+	c.Lock()
+	defer c.Unlock()
+	return c.pulls
+}
+
+// Pin sets the image digests, keyed by image name, used to
+// run pinned images. A pinned image with a digest is run by
+// that digest, for example the digest resolved by a previous
+// build, instead of the digest currently tagged.
+func (c *Client) Pin(digests map[string]string) {
+	c.Lock()
+	c.pinned = digests
+	c.Unlock()
+}
+
+// Digests returns the digest of every image run by this
+// client, keyed by image name.
+func (c *Client) Digests() map[string]string {
+	c.Lock()
+	defer c.Unlock()
+	digests := map[string]string{}
+	for image, digest := range c.digests {
+		digests[image] = digest
+	}
+	return digests
+}
+
+// CopyFrom returns a tar archive of the file or directory
+// at the path in the shared build volume.
+func (c *Client) CopyFrom(path string) (io.ReadCloser, error) {
+	client, ok := c.Client.(*dockerclient.DockerClient)
+	if !ok {
+		return nil, ErrArchive
+	}
+	return CopyFromContainer(client, c.info.Id, path)
+}
+
+// CopyTo extracts a tar archive into the directory at the
+// path in the shared build volume.
+func (c *Client) CopyTo(path string, r io.Reader) error {
+	client, ok := c.Client.(*dockerclient.DockerClient)
+	if !ok {
+		return ErrArchive
+	}
+	return CopyToContainer(client, c.info.Id, path, r)
 }
 
 // CreateContainer creates a container and internally
@@ -42,6 +182,18 @@ func (c *Client) CreateContainer(conf *dockerclient.ContainerConfig, name string
 	return id, err
 }
 
+// CreateVolume creates a volume and internally
+// caches its volume name.
+func (c *Client) CreateVolume(request *dockerclient.VolumeCreateRequest) (*dockerclient.Volume, error) {
+	vol, err := c.Client.CreateVolume(request)
+	if err == nil {
+		c.Lock()
+		c.vols = append(c.vols, vol.Name)
+		c.Unlock()
+	}
+	return vol, err
+}
+
 // StartContainer starts a container and links to an
 // ambassador container sharing the build machiens volume.
 func (c *Client) StartContainer(id string, conf *dockerclient.HostConfig) error {
@@ -52,13 +204,16 @@ func (c *Client) StartContainer(id string, conf *dockerclient.HostConfig) error
 	return c.Client.StartContainer(id, conf)
 }
 
-// Destroy will terminate and destroy all containers that
-// were created by this client.
+// Destroy will terminate and destroy all containers and
+// volumes that were created by this client.
 func (c *Client) Destroy() error {
 	for _, id := range c.names {
 		c.Client.KillContainer(id, "9")
 		c.Client.RemoveContainer(id, true, true)
 	}
+	for _, name := range c.vols {
+		c.Client.RemoveVolume(name)
+	}
 	c.Client.KillContainer(c.info.Id, "9")
 	return c.Client.RemoveContainer(c.info.Id, true, true)
 }
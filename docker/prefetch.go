@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/samalba/dockerclient"
+)
+
+// Prefetch pulls the images concurrently, in accordance with
+// each image pull policy, with at most limit pulls in progress
+// at once. Prefetch errors are ignored, since the error is
+// reported when the step using the image attempts the pull.
+func Prefetch(client dockerclient.Client, images map[string]yaml.PullPolicy, limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for image, pull := range images {
+		if pull == yaml.PullNever {
+			continue
+		}
+		wg.Add(1)
+		go func(image string, pull yaml.PullPolicy) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Debugf("Prefetching image %s", image)
+			err := Pull(client, image, pull)
+			if err != nil {
+				log.Debugf("Unable to prefetch image %s. %s", image, err)
+			}
+		}(image, pull)
+	}
+	wg.Wait()
+}
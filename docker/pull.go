@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/samalba/dockerclient"
+)
+
+// progressInterval defines how often the aggregate
+// download progress is written to the build output.
+var progressInterval = 10 * time.Second
+
+// PullInfo records the duration and size of an image pull.
+type PullInfo struct {
+	Image    string        `json:"image"`
+	Digest   string        `json:"digest,omitempty"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// pullMessage is a message in the JSON stream returned
+// by the Docker API while pulling an image.
+type pullMessage struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Error    string `json:"error"`
+	Progress struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// PullStream pulls an image using the Docker API and writes
+// a compact per-layer summary of the pull progress to w.
+func PullStream(client *dockerclient.DockerClient, image string, auth *dockerclient.AuthConfig, w io.Writer) (*PullInfo, error) {
+	start := time.Now()
+
+	v := url.Values{}
+	v.Set("fromImage", image)
+	uri := fmt.Sprintf("%s/%s/images/create?%s", client.URL, dockerclient.APIVersion, v.Encode())
+	req, err := http.NewRequest("POST", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		var buf bytes.Buffer
+		err = json.NewEncoder(&buf).Encode(auth)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("X-Registry-Auth", base64.URLEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &PullInfo{Image: image}
+	err = writeProgress(resp.Body, w, info)
+	info.Duration = time.Since(start)
+	return info, err
+}
+
+// writeProgress is a helper function that decodes the pull
+// progress stream and writes one line per completed layer,
+// with the aggregate download progress written periodically.
+func writeProgress(r io.Reader, w io.Writer, info *PullInfo) error {
+	var (
+		sizes = map[string]int64{} // total size of each layer
+		done  = map[string]int64{} // downloaded size of each layer
+		last  = time.Now()
+	)
+
+	dec := json.NewDecoder(r)
+	for {
+		msg := pullMessage{}
+		err := dec.Decode(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(msg.Error) != 0:
+			return errors.New(msg.Error)
+		case msg.Status == "Downloading":
+			sizes[msg.ID] = msg.Progress.Total
+			done[msg.ID] = msg.Progress.Current
+		case msg.Status == "Download complete":
+			done[msg.ID] = sizes[msg.ID]
+		case msg.Status == "Pull complete":
+			fmt.Fprintf(w, "%s: Pull complete %s\n", msg.ID, humanSize(sizes[msg.ID]))
+		case msg.Status == "Already exists":
+			fmt.Fprintf(w, "%s: Already exists\n", msg.ID)
+		case strings.HasPrefix(msg.Status, "Digest: "):
+			info.Digest = strings.TrimPrefix(msg.Status, "Digest: ")
+			fmt.Fprintln(w, msg.Status)
+		case strings.HasPrefix(msg.Status, "Status: "):
+			fmt.Fprintln(w, msg.Status)
+		}
+
+		if time.Since(last) >= progressInterval {
+			last = time.Now()
+			fmt.Fprintf(w, "Downloaded %s of %s\n",
+				humanSize(sum(done)),
+				humanSize(sum(sizes)),
+			)
+		}
+	}
+
+	info.Bytes = sum(sizes)
+	return nil
+}
+
+// sum is a helper function that returns the sum of
+// all values in the map.
+func sum(m map[string]int64) (total int64) {
+	for _, v := range m {
+		total += v
+	}
+	return
+}
+
+// humanSize is a helper function that returns a human
+// readable representation of the byte size.
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	i, f := 0, float64(size)
+	for f >= 1000 && i < len(units)-1 {
+		f = f / 1000
+		i++
+	}
+	return fmt.Sprintf("%.4g %s", f, units[i])
+}
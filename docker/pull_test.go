@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Pull(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Pull progress", func() {
+
+		g.It("Should write a per-layer summary", func() {
+			var buf bytes.Buffer
+			info := &PullInfo{}
+			err := writeProgress(strings.NewReader(pullStream), &buf, info)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(buf.String()).Equal(pullSummary)
+		})
+
+		g.It("Should record the digest and size", func() {
+			var buf bytes.Buffer
+			info := &PullInfo{}
+			writeProgress(strings.NewReader(pullStream), &buf, info)
+			g.Assert(info.Digest).Equal("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4")
+			g.Assert(info.Bytes).Equal(int64(32000000))
+		})
+
+		g.It("Should return pull errors", func() {
+			var buf bytes.Buffer
+			info := &PullInfo{}
+			err := writeProgress(strings.NewReader(pullError), &buf, info)
+			g.Assert(err != nil).IsTrue()
+			g.Assert(err.Error()).Equal("unauthorized: authentication required")
+		})
+
+		g.It("Should format human readable sizes", func() {
+			g.Assert(humanSize(512)).Equal("512 B")
+			g.Assert(humanSize(32000000)).Equal("32 MB")
+			g.Assert(humanSize(1500000000)).Equal("1.5 GB")
+		})
+	})
+}
+
+var pullStream = `
+{"status":"Pulling from library/golang","id":"1.5"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"efd26ecc9548"}
+{"status":"Already exists","progressDetail":{},"id":"a3ed95caeb02"}
+{"status":"Downloading","progressDetail":{"current":16000000,"total":32000000},"id":"efd26ecc9548"}
+{"status":"Downloading","progressDetail":{"current":32000000,"total":32000000},"id":"efd26ecc9548"}
+{"status":"Download complete","progressDetail":{},"id":"efd26ecc9548"}
+{"status":"Pull complete","progressDetail":{},"id":"efd26ecc9548"}
+{"status":"Digest: sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4"}
+{"status":"Status: Downloaded newer image for golang:1.5"}
+`
+
+var pullSummary = `a3ed95caeb02: Already exists
+efd26ecc9548: Pull complete 32 MB
+Digest: sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4
+Status: Downloaded newer image for golang:1.5
+`
+
+var pullError = `
+{"status":"Pulling repository registry.example.com/foo"}
+{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}
+`
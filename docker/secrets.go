@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drone/drone-exec/yaml"
+	"github.com/samalba/dockerclient"
+)
+
+// secretOpts are the options of the volume where secret files
+// are written, so that the files are only held in memory.
+var secretOpts = map[string]string{
+	"type":   "tmpfs",
+	"device": "tmpfs",
+	"o":      "mode=0700",
+}
+
+// mountFiles writes the files to an in-memory volume, and mounts
+// the volume read-only in the secrets directory of the container.
+// The files are mapped from absolute path, in the secrets
+// directory, to file contents.
+//
+// The in-memory volume only retains the files while mounted, and
+// the files are written by a container holding the volume. The id
+// of the writer container is returned, and the writer must be
+// removed once the container is started.
+func mountFiles(client dockerclient.Client, conf *dockerclient.ContainerConfig, files map[string]string) (string, error) {
+	vol, err := client.CreateVolume(&dockerclient.VolumeCreateRequest{
+		Driver:     "local",
+		DriverOpts: secretOpts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = Pull(client, ambassador, yaml.PullIfNotPresent)
+	if err != nil {
+		client.RemoveVolume(vol.Name)
+		return "", err
+	}
+
+	writer := &dockerclient.ContainerConfig{}
+	writer.HostConfig = dockerclient.HostConfig{
+		Binds:            []string{vol.Name + ":" + yaml.SecretDir},
+		NetworkMode:      "none",
+		MemorySwappiness: -1,
+	}
+	writer.Entrypoint = []string{"/bin/sleep"}
+	writer.Cmd = []string{"86400"}
+	writer.Image = ambassador
+	id, err := client.CreateContainer(writer, "")
+	if err == nil {
+		err = client.StartContainer(id, &writer.HostConfig)
+	}
+	if err == nil {
+		err = copyFiles(client, id, files)
+	}
+	if err != nil {
+		if len(id) != 0 {
+			client.RemoveContainer(id, true, true)
+		}
+		client.RemoveVolume(vol.Name)
+		return "", err
+	}
+
+	conf.HostConfig.Binds = append(conf.HostConfig.Binds, vol.Name+":"+yaml.SecretDir+":ro")
+	return id, nil
+}
+
+// RemoveFiles removes the container, along with the in-memory
+// volume of the files written for the container, if any.
+func RemoveFiles(client dockerclient.Client, info *dockerclient.ContainerInfo) error {
+	err := client.RemoveContainer(info.Id, true, true)
+	if info.HostConfig == nil {
+		return err
+	}
+	for _, bind := range info.HostConfig.Binds {
+		if strings.HasSuffix(bind, ":"+yaml.SecretDir+":ro") {
+			client.RemoveVolume(strings.TrimSuffix(bind, ":"+yaml.SecretDir+":ro"))
+		}
+	}
+	return err
+}
+
+// copyFiles writes the files to the secrets directory of the
+// container filesystem. The files are only readable by the owner.
+func copyFiles(client dockerclient.Client, id string, files map[string]string) error {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    strings.TrimPrefix(name, yaml.SecretDir+"/"),
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: time.Now(),
+		}
+		err := tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(tw, files[name])
+		if err != nil {
+			return err
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+
+	// the archive endpoints are not part of the client
+	// interface, and require the default client.
+	if c, ok := client.(*Client); ok {
+		client = c.Client
+	}
+	c, ok := client.(*dockerclient.DockerClient)
+	if !ok {
+		return ErrArchive
+	}
+	return CopyToContainer(c, id, yaml.SecretDir, &buf)
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/yaml"
+	"github.com/franela/goblin"
+	"github.com/samalba/dockerclient"
+)
+
+func Test_Secrets(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Secret files", func() {
+
+		files := map[string]string{"/run/secrets/password": "correct-horse"}
+
+		g.It("Should write files to the volume of the running container", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag(ambassador, "alpine", "gliderlabs/alpine@sha256:a3ed")
+			daemon.tag("golang:1.5", "golang", "golang@sha256:b4c2")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			conf := &dockerclient.ContainerConfig{Image: "golang:1.5"}
+			info, err := StartFiles(client, conf, yaml.PullIfNotPresent, false, files)
+			g.Assert(err == nil).IsTrue()
+
+			step := daemon.containers[info.Id]
+			g.Assert(step.Running).IsTrue()
+			vol, name, ok := daemon.mounted(step, "/run/secrets/password")
+			g.Assert(ok).IsTrue()
+			g.Assert(daemon.volumes[vol][name]).Equal("correct-horse")
+			g.Assert(info.HostConfig.Binds).Equal([]string{vol + ":/run/secrets:ro"})
+		})
+
+		g.It("Should not write files to the container layer", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag(ambassador, "alpine", "gliderlabs/alpine@sha256:a3ed")
+			daemon.tag("golang:1.5", "golang", "golang@sha256:b4c2")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			conf := &dockerclient.ContainerConfig{Image: "golang:1.5"}
+			info, err := StartFiles(client, conf, yaml.PullIfNotPresent, false, files)
+			g.Assert(err == nil).IsTrue()
+
+			// only the step container remains once the writer
+			// container is removed.
+			g.Assert(len(daemon.containers)).Equal(1)
+			g.Assert(len(daemon.containers[info.Id].Layer)).Equal(0)
+		})
+
+		g.It("Should remove the files with the container", func() {
+			daemon, docker := newFakeDaemon()
+			defer daemon.server.Close()
+			daemon.tag(ambassador, "alpine", "gliderlabs/alpine@sha256:a3ed")
+			daemon.tag("golang:1.5", "golang", "golang@sha256:b4c2")
+
+			client := &Client{Client: docker, info: &dockerclient.ContainerInfo{Id: "ambassador"}}
+			conf := &dockerclient.ContainerConfig{Image: "golang:1.5"}
+			info, err := StartFiles(client, conf, yaml.PullIfNotPresent, false, files)
+			g.Assert(err == nil).IsTrue()
+
+			err = RemoveFiles(client, info)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(daemon.containers)).Equal(0)
+			g.Assert(len(daemon.volumes)).Equal(0)
+		})
+	})
+}
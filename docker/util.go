@@ -1,17 +1,21 @@
 package docker
 
 import (
+	"bytes"
 	"errors"
+	"io/ioutil"
 	"os"
-	// "strings"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/drone/drone-exec/yaml"
 	"github.com/samalba/dockerclient"
 )
 
 var (
-	ErrTimeout = errors.New("Timeout")
-	ErrLogging = errors.New("Logs not available")
+	ErrTimeout  = errors.New("Timeout")
+	ErrLogging  = errors.New("Logs not available")
+	ErrNotFound = errors.New("Image not found")
 )
 
 var (
@@ -30,10 +34,18 @@ var (
 	}
 )
 
-func Run(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull bool) (*dockerclient.ContainerInfo, error) {
+func Run(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy, pin bool) (*dockerclient.ContainerInfo, error) {
+	return RunFiles(client, conf, pull, pin, nil)
+}
+
+// RunFiles runs the container to completion, writing the files
+// to an in-memory volume mounted in the secrets directory before
+// the container is started. The files are mapped from absolute
+// path to file contents.
+func RunFiles(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy, pin bool, files map[string]string) (*dockerclient.ContainerInfo, error) {
 
 	// fetches the container information.
-	info, err := Start(client, conf, pull)
+	info, err := start(client, conf, pull, pin, files)
 	if err != nil {
 		return nil, err
 	}
@@ -81,30 +93,92 @@ func Run(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull bo
 	}
 }
 
-func Start(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull bool) (*dockerclient.ContainerInfo, error) {
-	// force-pull the image if specified.
-	if pull {
-		log.Printf("Pulling image %s", conf.Image)
-		client.PullImage(conf.Image, nil)
+// Output runs the container to completion and returns the
+// container information along with the standard output.
+func Output(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy) (*dockerclient.ContainerInfo, []byte, error) {
+	info, err := Start(client, conf, pull, false)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer func() {
+		client.StopContainer(info.Id, 5)
+		client.KillContainer(info.Id, "9")
+	}()
 
-	// attempts to create the contianer
-	id, err := client.CreateContainer(conf, "")
+	rc, err := client.ContainerLogs(info.Id, logOptsTail)
 	if err != nil {
-		log.Printf("Pulling image %s", conf.Image)
+		return info, nil, err
+	}
+	defer rc.Close()
 
-		// and pull the image and re-create if that fails
-		err = client.PullImage(conf.Image, nil)
-		if err != nil {
-			log.Errorf("Error pulling %s. %s\n", conf.Image, err)
-			return nil, err
+	var stdout bytes.Buffer
+	_, err = StdCopy(&stdout, ioutil.Discard, rc)
+	if err != nil {
+		return info, nil, err
+	}
+	info, err = client.InspectContainer(info.Id)
+	return info, stdout.Bytes(), err
+}
+
+func Start(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy, pin bool) (*dockerclient.ContainerInfo, error) {
+	return start(client, conf, pull, pin, nil)
+}
+
+// StartFiles starts the container, writing the files to an
+// in-memory volume mounted in the secrets directory before the
+// container is started.
+func StartFiles(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy, pin bool, files map[string]string) (*dockerclient.ContainerInfo, error) {
+	return start(client, conf, pull, pin, files)
+}
+
+func start(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull yaml.PullPolicy, pin bool, files map[string]string) (*dockerclient.ContainerInfo, error) {
+	// a pinned image is run by the digest supplied to the
+	// client, if any, instead of the digest currently tagged.
+	image := conf.Image
+	if pin {
+		if digest, ok := pinnedDigest(client, image); ok {
+			log.Debugf("Pinning image %s to %s", image, digest)
+			conf.Image = digest
 		}
-		id, err = client.CreateContainer(conf, "")
+	}
+
+	// pulls the image in accordance with the pull policy.
+	err := Pull(client, conf.Image, pull)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolves the image digest so that the exact image used
+	// is recorded, and optionally pins the image to the digest.
+	digest, err := Digest(client, conf.Image)
+	if err != nil {
+		log.Debugf("Unable to resolve digest for %s. %s", conf.Image, err)
+	} else {
+		log.Printf("Using image %s (%s)", image, digest[strings.Index(digest, "@")+1:])
+		recordDigest(client, image, digest)
+		if pin {
+			conf.Image = digest
+		}
+	}
+
+	// writes the files before the container is created, so
+	// that the files exist when the container command runs.
+	// The writer is removed once the container is started
+	// and holds the in-memory volume in turn.
+	if len(files) != 0 {
+		writer, err := mountFiles(client, conf, files)
 		if err != nil {
-			log.Errorf("Error creating %s. %s\n", conf.Image, err)
-			client.RemoveContainer(id, true, true)
+			log.Errorf("Error writing files for %s. %s\n", conf.Image, err)
 			return nil, err
 		}
+		defer client.RemoveContainer(writer, true, true)
+	}
+
+	// attempts to create the contianer
+	id, err := client.CreateContainer(conf, "")
+	if err != nil {
+		log.Errorf("Error creating %s. %s\n", conf.Image, err)
+		return nil, err
 	}
 
 	// fetches the container information
@@ -122,3 +196,29 @@ func Start(client dockerclient.Client, conf *dockerclient.ContainerConfig, pull
 	}
 	return info, err
 }
+
+// Pull pulls the image in accordance with the pull policy. The
+// image presence is checked before pulling, unless the policy
+// requires the image is always pulled.
+func Pull(client dockerclient.Client, image string, pull yaml.PullPolicy) error {
+	if pull != yaml.PullAlways {
+		_, err := client.InspectImage(image)
+		switch {
+		case err == nil:
+			return nil
+		case err != dockerclient.ErrImageNotFound && err != dockerclient.ErrNotFound:
+			log.Errorf("Error inspecting image %s. %s\n", image, err)
+			return err
+		case pull == yaml.PullNever:
+			log.Errorf("Error image %s not found and pull policy is %s\n", image, pull)
+			return ErrNotFound
+		}
+	}
+
+	log.Printf("Pulling image %s", image)
+	err := client.PullImage(image, nil)
+	if err != nil {
+		log.Errorf("Error pulling %s. %s\n", image, err)
+	}
+	return err
+}
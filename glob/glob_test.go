@@ -0,0 +1,33 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Glob(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Glob patterns", func() {
+
+		g.It("Should match a single directory", func() {
+			g.Assert(Match("dist/*.tar.gz", "dist/app.tar.gz")).IsTrue()
+			g.Assert(Match("dist/*.tar.gz", "dist/linux/app.tar.gz")).IsFalse()
+			g.Assert(Match("*.go", "main.go")).IsTrue()
+		})
+
+		g.It("Should match any number of directories", func() {
+			g.Assert(Match("**/*.go", "main.go")).IsTrue()
+			g.Assert(Match("**/*.go", "cmd/drone/main.go")).IsTrue()
+			g.Assert(Match("services/api/**", "services/api/main.go")).IsTrue()
+			g.Assert(Match("services/api/**", "services/web/main.go")).IsFalse()
+			g.Assert(Match("services/**/Dockerfile", "services/api/v1/Dockerfile")).IsTrue()
+		})
+
+		g.It("Should not match partial names", func() {
+			g.Assert(Match("dist", "dist/app.tar.gz")).IsFalse()
+			g.Assert(Match("dist/app", "dist")).IsFalse()
+		})
+	})
+}
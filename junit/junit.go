@@ -0,0 +1,132 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Suite represents a JUnit test suite.
+type Suite struct {
+	Name  string  `xml:"name,attr" json:"name"`
+	Time  float64 `xml:"time,attr" json:"time"`
+	Cases []*Case `xml:"testcase" json:"cases"`
+}
+
+// Case represents a JUnit test case.
+type Case struct {
+	Name    string   `xml:"name,attr" json:"name"`
+	Class   string   `xml:"classname,attr" json:"class,omitempty"`
+	Time    float64  `xml:"time,attr" json:"time"`
+	Failure *Failure `xml:"failure" json:"failure,omitempty"`
+	Error   *Failure `xml:"error" json:"error,omitempty"`
+	Skipped *Skipped `xml:"skipped" json:"skipped,omitempty"`
+}
+
+// Failure represents a JUnit test failure or error.
+type Failure struct {
+	Message string `xml:"message,attr" json:"message"`
+	Type    string `xml:"type,attr" json:"type,omitempty"`
+	Text    string `xml:",chardata" json:"text,omitempty"`
+}
+
+// Skipped represents a skipped JUnit test.
+type Skipped struct {
+	Message string `xml:"message,attr" json:"message,omitempty"`
+}
+
+// Failed reports whether the test case failed.
+func (c *Case) Failed() bool {
+	return c.Failure != nil || c.Error != nil
+}
+
+// Report summarizes the results of the test suites.
+type Report struct {
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Skipped int      `json:"skipped"`
+	Suites  []*Suite `json:"suites"`
+}
+
+// Add adds the test suites to the report.
+func (r *Report) Add(suites ...*Suite) {
+	for _, suite := range suites {
+		for _, c := range suite.Cases {
+			switch {
+			case c.Failed():
+				r.Failed++
+			case c.Skipped != nil:
+				r.Skipped++
+			default:
+				r.Passed++
+			}
+		}
+		r.Suites = append(r.Suites, suite)
+	}
+}
+
+// Failures returns the first n failed test cases.
+func (r *Report) Failures(n int) []*Case {
+	var cases []*Case
+	for _, suite := range r.Suites {
+		for _, c := range suite.Cases {
+			if len(cases) == n {
+				return cases
+			}
+			if c.Failed() {
+				cases = append(cases, c)
+			}
+		}
+	}
+	return cases
+}
+
+// WriteSummary writes a summary of the test results to w,
+// including the messages of the first n failed test cases.
+func (r *Report) WriteSummary(w io.Writer, n int) {
+	fmt.Fprintf(w, "Tests: %d passed, %d failed, %d skipped\n", r.Passed, r.Failed, r.Skipped)
+	for _, c := range r.Failures(n) {
+		failure := c.Failure
+		if failure == nil {
+			failure = c.Error
+		}
+		message := failure.Message
+		if len(message) == 0 {
+			message = strings.TrimSpace(failure.Text)
+		}
+		if i := strings.Index(message, "\n"); i != -1 {
+			message = message[:i]
+		}
+		name := c.Name
+		if len(c.Class) != 0 {
+			name = c.Class + "." + c.Name
+		}
+		fmt.Fprintf(w, "FAIL %s: %s\n", name, message)
+	}
+	if r.Failed > n {
+		fmt.Fprintf(w, "... and %d more failures\n", r.Failed-n)
+	}
+}
+
+// Parse parses the JUnit XML report. The report may contain
+// a single test suite or multiple test suites.
+func Parse(r io.Reader) ([]*Suite, error) {
+	var doc = struct {
+		XMLName xml.Name
+		Suite
+		Suites []*Suite `xml:"testsuite"`
+	}{}
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "testsuites":
+		return doc.Suites, nil
+	case "testsuite":
+		suite := doc.Suite
+		return []*Suite{&suite}, nil
+	}
+	return nil, fmt.Errorf("Invalid JUnit report element %s", doc.XMLName.Local)
+}
@@ -0,0 +1,82 @@
+package junit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_JUnit(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("JUnit reports", func() {
+
+		g.It("Should parse a test suite", func() {
+			suites, err := Parse(strings.NewReader(suite))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(suites)).Equal(1)
+			g.Assert(suites[0].Name).Equal("math")
+			g.Assert(len(suites[0].Cases)).Equal(4)
+			g.Assert(suites[0].Cases[1].Failure.Message).Equal("expected 4, got 5")
+		})
+
+		g.It("Should parse multiple test suites", func() {
+			suites, err := Parse(strings.NewReader(suites))
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(suites)).Equal(2)
+			g.Assert(suites[1].Name).Equal("strings")
+		})
+
+		g.It("Should error when the report is invalid", func() {
+			_, err := Parse(strings.NewReader("<html></html>"))
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should summarize the test results", func() {
+			parsed, _ := Parse(strings.NewReader(suite))
+			report := &Report{}
+			report.Add(parsed...)
+			g.Assert(report.Passed).Equal(1)
+			g.Assert(report.Failed).Equal(2)
+			g.Assert(report.Skipped).Equal(1)
+
+			var buf bytes.Buffer
+			report.WriteSummary(&buf, 1)
+			g.Assert(buf.String()).Equal(summary)
+		})
+	})
+}
+
+var suite = `
+<testsuite name="math" tests="4" time="0.02">
+  <testcase classname="math" name="TestAdd" time="0.01"/>
+  <testcase classname="math" name="TestSub" time="0.01">
+    <failure message="expected 4, got 5" type="assert">math_test.go:12</failure>
+  </testcase>
+  <testcase classname="math" name="TestDiv">
+    <error>division by zero
+goroutine 1 [running]</error>
+  </testcase>
+  <testcase classname="math" name="TestMul">
+    <skipped/>
+  </testcase>
+</testsuite>
+`
+
+var suites = `
+<testsuites>
+  <testsuite name="math">
+    <testcase name="TestAdd"/>
+  </testsuite>
+  <testsuite name="strings">
+    <testcase name="TestTrim"/>
+  </testsuite>
+</testsuites>
+`
+
+var summary = `Tests: 1 passed, 2 failed, 1 skipped
+FAIL math.TestSub: expected 4, got 5
+... and 1 more failures
+`
@@ -2,16 +2,22 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/drone/drone-exec/artifact"
+	wcache "github.com/drone/drone-exec/cache"
 	"github.com/drone/drone-exec/docker"
+	"github.com/drone/drone-exec/junit"
 	"github.com/drone/drone-exec/parser"
 	"github.com/drone/drone-exec/runner"
 	"github.com/drone/drone-exec/yaml"
@@ -33,7 +39,20 @@ var (
 	notify bool   // execute notify steps
 	debug  bool   // execute in debug mode
 	force  bool   // force pull plugin images
+	pin    bool   // pin plugin images to their digest
+	limit  int    // limit of concurrent image prefetch pulls
 	mount  string // mounts the volume on the host machine
+	config string // path to the host docker config.json
+	dir    string // host directory of the workspace cache
+	quota  string // global quota of the host cache
+	rquota string // per-repository quota of the host cache
+	prsave bool   // allow pull requests to write to the cache
+	output string // host directory of the build artifacts
+	result string // path of the structured build result
+	strict bool   // fail when yaml parameters are undefined
+	keyset string // path of the trusted yaml signing keys
+	pinned string // path of a previous report of pinned digests
+	bound  bool   // reject secrets not bound to a repository
 )
 
 // payload defines the raw plugin payload that
@@ -41,6 +60,7 @@ var (
 var payload = struct {
 	Yaml      string            `json:"config"`
 	YamlEnc   string            `json:"secret"`
+	YamlSig   string            `json:"signature"`
 	Repo      *plugin.Repo      `json:"repo"`
 	Build     *plugin.Build     `json:"build"`
 	BuildLast *plugin.Build     `json:"build_last"`
@@ -49,6 +69,8 @@ var payload = struct {
 	Keys      *plugin.Keypair   `json:"keys"`
 	System    *plugin.System    `json:"system"`
 	Workspace *plugin.Workspace `json:"workspace"`
+	Auths     []*docker.Auth    `json:"registries"`
+	Digests   map[string]string `json:"digests"`
 }{}
 
 func main() {
@@ -61,9 +83,31 @@ func main() {
 	flag.BoolVar(&notify, "notify", false, "")
 	flag.BoolVar(&debug, "debug", false, "")
 	flag.BoolVar(&force, "pull", false, "")
+	flag.BoolVar(&pin, "pin", false, "")
+	flag.IntVar(&limit, "prefetch", 4, "")
 	flag.StringVar(&mount, "mount", "", "")
+	flag.StringVar(&config, "docker-config", docker.AuthFilePath(), "")
+	flag.StringVar(&dir, "cache-dir", wcache.DefaultRoot, "")
+	flag.StringVar(&quota, "cache-quota", "", "")
+	flag.StringVar(&rquota, "cache-repo-quota", "", "")
+	flag.BoolVar(&prsave, "cache-pull-request", false, "")
+	flag.StringVar(&output, "artifacts-dir", "", "")
+	flag.StringVar(&result, "report", "", "")
+	flag.BoolVar(&strict, "strict-vars", false, "")
+	flag.StringVar(&keyset, "trusted-keys", "", "")
+	flag.StringVar(&pinned, "pin-report", "", "")
+	flag.BoolVar(&bound, "require-secret-repo", false, "")
 	flag.Parse()
 
+	// executes the cache maintenance and secrets subcommands,
+	// which do not require a build payload.
+	switch flag.Arg(0) {
+	case "cache":
+		os.Exit(cacheCommand(flag.Args()[1:]))
+	case "secure":
+		os.Exit(secureCommand(flag.Args()[1:]))
+	}
+
 	// unmarshal the json payload via stdin or
 	// via the command line args (whichever was used)
 	plugin.MustUnmarshal(&payload)
@@ -76,17 +120,46 @@ func main() {
 	}
 	log.SetFormatter(new(formatter))
 
+	// strict mode is enabled before parameters are injected,
+	// since injection may alter the yaml.
+	strict = strict || yaml.ParseStrictVarsString(payload.Yaml)
+
+	// the yaml is re-encoded when parameters are injected, so
+	// the original yaml is used to report line numbers.
+	orig := payload.Yaml
+	injected := map[string]bool{}
+	secrets := map[string]secure.Secret{}
+
 	var sec *secure.Secure
 	if payload.Keys != nil && len(payload.YamlEnc) != 0 {
 		var err error
-		sec, err = secure.Parse(payload.YamlEnc, payload.Keys.Private)
-		if err != nil {
-			log.Debugln("Unable to decrypt encrypted secrets", err)
-		} else {
+		sec, err = secure.Parse(payload.YamlEnc, payload.Keys.Private, payload.Repo.FullName)
+		switch err {
+		case nil:
 			log.Debugln("Successfully decrypted secrets")
+		case secure.ErrRepo, secure.ErrExpired, secure.ErrNotBefore:
+			// the secrets are decrypted but not valid for this
+			// build, and the reason is always reported.
+			fmt.Printf("Unable to use the encrypted secrets. %s\n", err)
+		default:
+			log.Debugln("Unable to decrypt encrypted secrets", err)
 		}
 
+		// secrets not bound to a repository can be copied to
+		// any repository, and are rejected when required.
+		if sec != nil && len(sec.Repo) == 0 {
+			if bound {
+				fmt.Printf("Unable to use the encrypted secrets. %s\n", secure.ErrUnbound)
+				sec = nil
+			} else {
+				log.Warnf("%s. The secrets can be used by any repository", secure.ErrUnbound)
+			}
+		}
 	}
+	// registry credentials provided by the payload take
+	// precedence over the secrets file and the host config.
+	auths := payload.Auths
+
 	// TODO This block of code (and the above block) need to be cleaned
 	//      up and written in a manner that facilitates better unit testing.
 	if sec != nil {
@@ -99,18 +172,44 @@ func main() {
 			verified = false
 		}
 
+		// a Yaml file signed with a trusted key is verified
+		// regardless of the checksum, and a Yaml file with a
+		// bad or untrusted signature is never verified. An
+		// unsigned Yaml file is only verified by a checksum
+		// that is provided and matches.
+		if len(keyset) != 0 {
+			switch err := verifySignature(orig, payload.YamlSig, sec.Checksum, keyset); err {
+			case nil:
+				log.Debugln("Verified Yaml signature or checksum")
+				verified = true
+			case secure.ErrUnsigned:
+				log.Debugln(err)
+				verified = false
+			default:
+				fmt.Println("Unable to verify Yaml signature.", err)
+				verified = false
+			}
+		}
+
 		switch {
 		case verified && payload.Build.Event == plugin.EventPull:
 			log.Debugln("Injected secrets into Yaml safely")
+			injectReport("secret", orig, sec.Environment.Unscoped(), injected)
 			var err error
-			payload.Yaml, err = inject.InjectSafe(payload.Yaml, sec.Environment.Map())
+			payload.Yaml, err = inject.InjectSafe(payload.Yaml, sec.Environment.Unscoped())
 			if err != nil {
 				fmt.Println("Error injecting Yaml secrets")
 				os.Exit(1)
 			}
 		case verified:
 			log.Debugln("Injected secrets into Yaml")
-			payload.Yaml = inject.Inject(payload.Yaml, sec.Environment.Map())
+			injectReport("secret", orig, sec.Environment.Unscoped(), injected)
+			var err error
+			payload.Yaml, err = inject.Inject(payload.Yaml, sec.Environment.Unscoped())
+			if err != nil {
+				fmt.Println("Error injecting Yaml secrets")
+				os.Exit(1)
+			}
 		case !verified:
 			// if we can't validate the Yaml file we don't inject
 			// secrets, and therefore shouldn't bother running the
@@ -119,6 +218,32 @@ func main() {
 			notify = false
 			fmt.Println("Unable to validate Yaml checksum.", sec.Checksum)
 		}
+
+		if verified {
+			// secrets restricted to matching steps are injected
+			// into the steps below, and secrets requested by the
+			// steps are added when parsing the steps.
+			secrets = sec.Environment.Secrets()
+
+			for _, auth := range sec.Registries {
+				auths = append(auths, &docker.Auth{
+					Registry: auth.Registry,
+					Username: auth.Username,
+					Password: auth.Password,
+					Email:    auth.Email,
+				})
+			}
+		}
+	}
+
+	// appends the registry credentials from the host
+	// machine's docker config.json file, if one exists.
+	if len(config) != 0 {
+		hostAuths, err := docker.ParseAuthFile(config)
+		if err != nil && !os.IsNotExist(err) {
+			log.Debugln("Unable to parse docker config", err)
+		}
+		auths = append(auths, hostAuths...)
 	}
 
 	// injects the matrix configuration parameters
@@ -132,8 +257,12 @@ func main() {
 	if payload.Build.Event == plugin.EventTag {
 		injectParams["TAG"] = strings.TrimPrefix(payload.Build.Ref, "refs/tags/")
 	}
-	payload.Yaml = inject.Inject(payload.Yaml, payload.Job.Environment)
-	payload.Yaml = inject.Inject(payload.Yaml, injectParams)
+	// the yaml is left unchanged if it cannot be parsed, in
+	// which case the error is reported when parsing below.
+	injectReport("matrix", orig, payload.Job.Environment, injected)
+	payload.Yaml, _ = inject.Inject(payload.Yaml, payload.Job.Environment)
+	injectReport("build", orig, injectParams, injected)
+	payload.Yaml, _ = inject.Inject(payload.Yaml, injectParams)
 
 	// safely inject global variables
 	var globals = map[string]string{}
@@ -144,12 +273,44 @@ func main() {
 		}
 		globals[parts[0]] = parts[1]
 	}
+	injectReport("global", orig, globals, injected)
 	if payload.Repo.IsPrivate {
-		payload.Yaml = inject.Inject(payload.Yaml, globals)
+		payload.Yaml, _ = inject.Inject(payload.Yaml, globals)
 	} else {
 		payload.Yaml, _ = inject.InjectSafe(payload.Yaml, globals)
 	}
 
+	// injects the secrets restricted to matching steps before
+	// the yaml is resolved, so that escaped parameters and
+	// parameters in injected values are not expanded.
+	var err error
+	payload.Yaml, err = parser.InjectSecrets(payload.Yaml, secrets, payload.Build.Event, payload.Build.Event == plugin.EventPull)
+	if err != nil {
+		log.Debugln(err) // print error messages in debug mode only
+		log.Fatalln("Error parsing the .drone.yml")
+	}
+
+	// lists the parameters that are not defined, which fails
+	// the build in strict mode. Scoped secrets not referenced
+	// by a step are left unchanged.
+	var undefined []inject.Ref
+	for _, ref := range inject.Unresolved(orig, payload.Yaml) {
+		if _, ok := secrets[ref.Name]; !ok {
+			undefined = append(undefined, ref)
+		}
+	}
+	for _, ref := range undefined {
+		if strict {
+			fmt.Printf("Undefined variable $$%s at line %d\n", ref.Name, ref.Line)
+		} else {
+			log.Debugf("Undefined variable $$%s at line %d", ref.Name, ref.Line)
+		}
+	}
+	if strict && len(undefined) != 0 {
+		os.Exit(1)
+	}
+	payload.Yaml, _ = inject.Resolve(payload.Yaml)
+
 	// extracts the clone path from the yaml. If
 	// the clone path doesn't exist it uses a path
 	// derrived from the repository uri.
@@ -158,16 +319,50 @@ func main() {
 	payload.Workspace.Root = "/drone/src"
 	log.Debugf("Using workspace %s", payload.Workspace.Path)
 
+	// pull requests restore the cache of the target branch
+	// and cannot write to the cache unless explicitly allowed,
+	// to prevent a pull request from poisoning the cache.
+	cacheBranch, cacheWrite := cacheScope(payload.Build, prsave)
+	cacheDir := wcache.PluginDir(payload.Repo.FullName, cacheBranch)
+	if !cacheWrite {
+		log.Debugf("Using read-only cache of branch %s", cacheBranch)
+	}
+
+	// the cache plugins restore the cache of the default branch,
+	// or the repository cache used before caches were stored per
+	// branch, if the branch has no cache. The fallback cache is
+	// mounted read-only, and the cache of the branch is saved by
+	// the post-build cache steps.
+	restoreDir := wcache.PluginFallback(dir, payload.Repo.FullName, cacheBranch, payload.Repo.Branch)
+	if restoreDir != cacheDir {
+		log.Debugf("Using fallback cache %s", restoreDir)
+	}
+
+	// the plugin cache directories are locked while mounted by
+	// the build, so that the directories are not evicted when
+	// the cache is pruned concurrently.
+	if cache {
+		for _, name := range []string{cacheDir, restoreDir} {
+			lock, err := wcache.Lock(filepath.Join(dir, name))
+			if err != nil {
+				log.Debugf("Unable to lock cache %s. %s", name, err)
+				continue
+			}
+			defer lock.Close()
+		}
+	}
+
 	rules := []parser.RuleFunc{
 		parser.ImageName,
 		parser.ImageMatchFunc(payload.System.Plugins),
+		parser.SecretFunc(secrets, payload.Build.Event, payload.Build.Event == plugin.EventPull),
 		parser.ImagePullFunc(force),
+		parser.ImagePinFunc(pin),
 		parser.SanitizeFunc(payload.Repo.IsTrusted), //&& !plugin.PullRequest(payload.Build)
-		parser.CacheFunc(payload.Repo.FullName),
+		parser.CacheFunc(dir, restoreDir, !cacheWrite || restoreDir != cacheDir),
 		parser.DebugFunc(debugFlag),
 		parser.Escalate,
 		parser.HttpProxy,
-		parser.DefaultNotifyFilter,
 	}
 	if len(mount) != 0 {
 		log.Debugf("Mounting %s as workspace %s",
@@ -179,7 +374,19 @@ func main() {
 			payload.Workspace.Path,
 		))
 	}
-	tree, err := parser.Parse(payload.Yaml, rules)
+	conf, err := yaml.ParseString(payload.Yaml)
+	if err != nil {
+		log.Debugln(err) // print error messages in debug mode only
+		log.Fatalln("Error parsing the .drone.yml")
+		os.Exit(1)
+	}
+	tree, err := parser.Load(conf, rules)
+	if err != nil {
+		log.Debugln(err) // print error messages in debug mode only
+		log.Fatalln("Error parsing the .drone.yml")
+		os.Exit(1)
+	}
+	artifacts, err := parser.NewFilterNode(conf.Artifacts.Filter)
 	if err != nil {
 		log.Debugln(err) // print error messages in debug mode only
 		log.Fatalln("Error parsing the .drone.yml")
@@ -196,7 +403,7 @@ func main() {
 
 	// // creates a wrapper Docker client that uses an ambassador
 	// // container to create a pod-like environment.
-	controller, err := docker.NewClient(client)
+	controller, err := docker.NewClient(client, auths)
 	if err != nil {
 		log.Debugln(err)
 		log.Fatalln("Error creating the docker ambassador.")
@@ -204,6 +411,20 @@ func main() {
 	}
 	defer controller.Destroy()
 
+	// pinned plugins are run by the digests resolved by a
+	// previous build, if provided, so that re-running a build
+	// runs the exact same plugins.
+	if pin {
+		digests, err := readDigests(pinned)
+		if err != nil {
+			log.Errorf("Error reading the pinned digests. %s", err)
+		}
+		for image, digest := range payload.Digests {
+			digests[image] = digest
+		}
+		controller.Pin(digests)
+	}
+
 	// watch for sigkill (timeout or cancel build)
 	killc := make(chan os.Signal, 1)
 	signal.Notify(killc, syscall.SIGINT, syscall.SIGTERM)
@@ -236,12 +457,40 @@ func main() {
 		System:    payload.System,
 		Workspace: payload.Workspace,
 	}
+
+	// prefetches the images of every step that may execute
+	// concurrently, while the cache and clone steps run.
+	if limit > 0 {
+		var flags parser.NodeType
+		if cache {
+			flags |= parser.NodeCache
+		}
+		if clone {
+			flags |= parser.NodeClone
+		}
+		if build {
+			flags |= parser.NodeCompose | parser.NodeBuild
+		}
+		if deploy {
+			flags |= parser.NodePublish | parser.NodeDeploy
+		}
+		if notify {
+			flags |= parser.NodeNotify
+		}
+		if flags != 0 {
+			go docker.Prefetch(controller, r.Images(state, flags), limit)
+		}
+	}
+
 	if cache {
 		log.Debugln("Running Cache step")
 		err = r.RunNode(state, parser.NodeCache)
 		if err != nil {
 			log.Debugln(err)
 		}
+		// touch the plugin cache directory to record the
+		// last access time used for eviction.
+		wcache.Touch(filepath.Join(dir, restoreDir))
 	}
 	if clone {
 		log.Debugln("Running Clone step")
@@ -250,6 +499,35 @@ func main() {
 			log.Debugln(err)
 		}
 	}
+
+	// computes the files changed since the previous build
+	// for steps filtered by path. If the changed files are
+	// unknown the path filters are ignored.
+	if clone && !state.Failed() && r.HasPathFilter() {
+		state.Changes, err = runner.Changes(state)
+		if err != nil {
+			log.Warnf("Unable to compute changed files, ignoring when.path filters. %s", err)
+		}
+	}
+
+	// the workspace cache is restored after the clone step,
+	// and saved if the build and compose steps are successful.
+	var wc *wcache.Cache
+	if cache && build && len(conf.Cache.Paths) != 0 {
+		wc = &wcache.Cache{
+			Root:      dir,
+			Repo:      payload.Repo.FullName,
+			Branch:    cacheBranch,
+			Default:   payload.Repo.Branch,
+			Workspace: payload.Workspace.Path,
+			Volume:    controller,
+		}
+	}
+	if wc != nil && !state.Failed() {
+		log.Debugln("Restoring the workspace cache")
+		restoreCache(wc, conf.Cache, state)
+	}
+
 	if build && !state.Failed() {
 		log.Debugln("Running Build and Compose steps")
 		err = r.RunNode(state, parser.NodeCompose|parser.NodeBuild)
@@ -257,6 +535,19 @@ func main() {
 			log.Debugln(err)
 		}
 	}
+	if wc != nil && cacheWrite && !state.Failed() {
+		log.Debugln("Saving the workspace cache")
+		saveCache(wc, conf.Cache, state)
+	}
+
+	// the artifacts are collected after the build steps,
+	// including failed builds if the conditions match.
+	if build && len(output) != 0 && len(conf.Artifacts.Paths) != 0 {
+		if runner.Match(artifacts, state) {
+			log.Debugln("Collecting build artifacts")
+			collectArtifacts(controller, conf.Artifacts, state)
+		}
+	}
 	if deploy && !state.Failed() {
 		log.Debugln("Running Publish and Deploy steps")
 		err = r.RunNode(state, parser.NodePublish|parser.NodeDeploy)
@@ -272,13 +563,20 @@ func main() {
 		state.Build.Status = plugin.StateSuccess
 	}
 
-	if cache {
+	if cache && cacheWrite {
 		log.Debugln("Running post-Build Cache steps")
+		if restoreDir != cacheDir {
+			tree.Apply(parser.NodeCache, parser.CacheFunc(dir, cacheDir, false))
+		}
 		err = r.RunNode(state, parser.NodeCache)
 		if err != nil {
 			log.Debugln(err)
 		}
 	}
+	if cache && (len(quota) != 0 || len(rquota) != 0) {
+		log.Debugln("Pruning the host cache")
+		pruneCache(dir, quota, rquota)
+	}
 	if notify {
 		log.Debugln("Running Notify steps")
 		err = r.RunNode(state, parser.NodeNotify)
@@ -287,12 +585,191 @@ func main() {
 		}
 	}
 
+	if len(result) != 0 {
+		err = writeResult(result, controller, state)
+		if err != nil {
+			log.Errorf("Error writing the build report. %s", err)
+		}
+	}
+
 	if state.Failed() {
 		controller.Destroy()
 		os.Exit(state.ExitCode())
 	}
 }
 
+// writeResult is a helper function that writes the structured
+// build result, including the image pulls and test results,
+// to the named file in json format.
+func writeResult(name string, client *docker.Client, state *runner.State) error {
+	out := struct {
+		Status  string             `json:"status"`
+		Pulls   []*docker.PullInfo `json:"pulls"`
+		Digests map[string]string  `json:"digests"`
+		Tests   *junit.Report      `json:"tests,omitempty"`
+	}{
+		Status:  state.Job.Status,
+		Pulls:   client.Pulls(),
+		Digests: client.Digests(),
+		Tests:   state.Tests,
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// readDigests is a helper function that reads the image
+// digests from the named build result, written by a previous
+// build. An empty map is returned if no file is named.
+func readDigests(name string) (map[string]string, error) {
+	out := struct {
+		Digests map[string]string `json:"digests"`
+	}{}
+	if len(name) != 0 {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return map[string]string{}, err
+		}
+		err = json.Unmarshal(data, &out)
+		if err != nil {
+			return map[string]string{}, err
+		}
+	}
+	if out.Digests == nil {
+		out.Digests = map[string]string{}
+	}
+	return out.Digests, nil
+}
+
+// cacheScope is a helper function that returns the branch
+// namespace of the cache, and whether the build may write to
+// the cache. Pull requests use the cache of the target branch
+// read-only, or the cache of the pull request ref when writes
+// are explicitly allowed.
+func cacheScope(build *plugin.Build, allow bool) (string, bool) {
+	if build.Event != plugin.EventPull {
+		return build.Branch, true
+	}
+	if allow {
+		return build.Ref, true
+	}
+	// the refspec of a pull request is formatted as
+	// the source and target branch, head:base.
+	if parts := strings.SplitN(build.Refspec, ":", 2); len(parts) == 2 && len(parts[1]) != 0 {
+		return parts[1], false
+	}
+	return build.Branch, false
+}
+
+// injectReport is a helper function that logs the parameters
+// injected into the yaml, with the values masked. Parameters
+// already injected from another source are skipped.
+func injectReport(source, raw string, params map[string]string, injected map[string]bool) {
+	for _, ref := range inject.Substituted(raw, params) {
+		if injected[ref.Name] {
+			continue
+		}
+		log.Debugf("Injected %s variable $$%s=******** at line %d", source, ref.Name, ref.Line)
+	}
+	for name := range params {
+		injected[name] = true
+	}
+}
+
+// verifySignature is a helper function that verifies the
+// detached signature of the yaml with the trusted keys read
+// from the named file, or the checksum if the yaml is unsigned.
+func verifySignature(raw, sig, checksum, name string) error {
+	trusted, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return secure.VerifyYaml(raw, sig, string(trusted), checksum)
+}
+
+// restoreCache is a helper function that restores the
+// workspace cache. Cache errors do not fail the build.
+func restoreCache(c *wcache.Cache, conf yaml.Cache, state *runner.State) {
+	data := &wcache.KeyData{
+		Branch: state.Build.Branch,
+		Commit: state.Build.Commit,
+		Event:  state.Build.Event,
+	}
+	key, err := wcache.Key(c.Volume, c.Workspace, conf.Key, data)
+	if err != nil {
+		log.Errorf("Error rendering cache key %s. %s", conf.Key, err)
+		return
+	}
+	var fallback []string
+	for _, tmpl := range conf.Fallback {
+		f, err := wcache.Key(c.Volume, c.Workspace, tmpl, data)
+		if err != nil {
+			log.Errorf("Error rendering cache key %s. %s", tmpl, err)
+			continue
+		}
+		fallback = append(fallback, f)
+	}
+	match, err := c.Restore(key, fallback, conf.Paths)
+	if err != nil {
+		log.Printf("Unable to restore cache key %s. %s", key, err)
+		return
+	}
+	log.Printf("Restored cache key %s", match)
+}
+
+// saveCache is a helper function that saves the workspace
+// cache. Cache errors do not fail the build.
+func saveCache(c *wcache.Cache, conf yaml.Cache, state *runner.State) {
+	data := &wcache.KeyData{
+		Branch: state.Build.Branch,
+		Commit: state.Build.Commit,
+		Event:  state.Build.Event,
+	}
+	key, err := wcache.Key(c.Volume, c.Workspace, conf.Key, data)
+	if err != nil {
+		log.Errorf("Error rendering cache key %s. %s", conf.Key, err)
+		return
+	}
+	err = c.Save(key, conf.Paths)
+	if err != nil {
+		log.Errorf("Error saving cache key %s. %s", key, err)
+		return
+	}
+	log.Printf("Saved cache key %s", key)
+}
+
+// collectArtifacts is a helper function that copies the
+// build artifacts to the host machine and lists each file.
+// Artifact errors do not fail the build.
+func collectArtifacts(src artifact.Source, conf yaml.Artifacts, state *runner.State) {
+	files, err := artifact.Collect(src, state.Workspace.Path, conf.Paths, output)
+	for _, file := range files {
+		fmt.Fprintf(state.Stdout, "Collected artifact %s (%s)\n", file.Path, humanSize(file.Size))
+	}
+	if err != nil {
+		log.Errorf("Error collecting artifacts. %s", err)
+	}
+}
+
+// pruneCache is a helper function that evicts the least
+// recently used cache directories exceeding the quotas.
+func pruneCache(root, quota, repoQuota string) {
+	m, err := newManager(root, quota, repoQuota)
+	if err != nil {
+		log.Errorf("Error parsing cache quota. %s", err)
+		return
+	}
+	evicted, err := m.Prune()
+	for _, entry := range evicted {
+		log.Debugf("Evicted cache %s", entry.Path)
+	}
+	if err != nil {
+		log.Errorf("Error pruning the cache. %s", err)
+	}
+}
+
 type formatter struct{}
 
 func (f *formatter) Format(entry *log.Entry) ([]byte, error) {
@@ -7,11 +7,15 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/drone/drone-exec/yaml"
+	"github.com/drone/drone-exec/yaml/secure"
 )
 
 var (
 	ErrImageMissing   = errors.New("Yaml must specify an image for every step")
 	ErrImageWhitelist = errors.New("Yaml must specify am image from the white-list")
+	ErrSecretFile     = errors.New("Yaml must specify secret files in the " + yaml.SecretDir + " directory")
 )
 
 const (
@@ -95,6 +99,9 @@ func ImageMatchFunc(patterns []string) RuleFunc {
 	}
 }
 
+// ImagePull transforms plugin Nodes to always pull the image
+// when force-pull is enabled, overriding the pull policy
+// defined in the Yaml.
 func ImagePull(n Node, pull bool) error {
 	d, ok := n.(*DockerNode)
 	if !ok {
@@ -104,7 +111,9 @@ func ImagePull(n Node, pull bool) error {
 	case NodeBuild, NodeCompose:
 		return nil
 	}
-	d.Pull = pull
+	if pull {
+		d.Pull = yaml.PullAlways
+	}
 	return nil
 }
 
@@ -114,6 +123,28 @@ func ImagePullFunc(pull bool) RuleFunc {
 	}
 }
 
+// ImagePin transforms plugin Nodes to run the image by its
+// digest, resolved after the image is pulled, so that the
+// exact same plugin is used when the build is re-run.
+func ImagePin(n Node, pin bool) error {
+	d, ok := n.(*DockerNode)
+	if !ok {
+		return nil
+	}
+	switch d.NodeType {
+	case NodeBuild, NodeCompose:
+		return nil
+	}
+	d.Pin = pin
+	return nil
+}
+
+func ImagePinFunc(pin bool) RuleFunc {
+	return func(n Node) error {
+		return ImagePin(n, pin)
+	}
+}
+
 // Sanitize sanitizes a Docker Node by removing any potentially
 // harmful configuration options.
 func Sanitize(n Node) error {
@@ -156,37 +187,6 @@ func Escalate(n Node) error {
 	return nil
 }
 
-func DefaultNotifyFilter(n Node) error {
-	f, ok := n.(*FilterNode)
-	if !ok || f.Node == nil {
-		return nil
-	}
-
-	d, ok := f.Node.(*DockerNode)
-	if !ok {
-		return nil
-	}
-	if d.NodeType != NodeNotify {
-		return nil
-	}
-	empty := len(f.Success) == 0 &&
-		len(f.Failure) == 0 &&
-		len(f.Change) == 0
-
-	if !empty {
-		if len(f.Success) == 0 {
-			f.Success = "false"
-		}
-		if len(f.Failure) == 0 {
-			f.Failure = "false"
-		}
-		if len(f.Change) == 0 {
-			f.Change = "false"
-		}
-	}
-	return nil
-}
-
 // HttpProxy injects the HTTP_PROXY and HTTPS_PROXY environment
 // variables into the container.
 func HttpProxy(n Node) error {
@@ -206,22 +206,27 @@ func HttpProxy(n Node) error {
 }
 
 // Cache transforms the Docker Node to mount a volume to the host
-// machines local cache.
-func Cache(n Node, dir string) error {
+// machines local cache, where the directory is relative to the
+// cache root. The volume is mounted read-only when the build is
+// not permitted to write to the cache.
+func Cache(n Node, root, dir string, readonly bool) error {
 	d, ok := n.(*DockerNode)
 	if !ok {
 		return nil
 	}
 	if d.NodeType == NodeCache {
-		dir = fmt.Sprintf("/var/lib/drone/cache/%s:/cache", dir)
+		dir = fmt.Sprintf("%s:/cache", path.Join(root, dir))
+		if readonly {
+			dir = dir + ":ro"
+		}
 		d.Volumes = []string{dir}
 	}
 	return nil
 }
 
-func CacheFunc(dir string) RuleFunc {
+func CacheFunc(root, dir string, readonly bool) RuleFunc {
 	return func(n Node) error {
-		return Cache(n, dir)
+		return Cache(n, root, dir, readonly)
 	}
 }
 
@@ -263,6 +268,62 @@ func MountFunc(from, to string) RuleFunc {
 	}
 }
 
+// Secret transforms the Docker Node to add the secrets requested
+// by the step to the container environment, and to write the
+// secret files requested by the step to the container filesystem.
+// An error is returned if the secret is requested by a step with
+// an image that is not allowed. The secret is not exposed to
+// builds triggered by an event that is not allowed, or to the
+// build step when safe is true. Secrets referenced by the step
+// are injected by InjectSecrets.
+func Secret(n Node, secrets map[string]secure.Secret, event string, safe bool) error {
+	d, ok := n.(*DockerNode)
+	if !ok || len(secrets) == 0 {
+		return nil
+	}
+	expose := func(secret secure.Secret) bool {
+		return secret.MatchEvent(event) && !(safe && d.NodeType == NodeBuild)
+	}
+	for _, name := range d.Secrets {
+		secret, ok := secrets[name]
+		if !ok {
+			continue
+		}
+		if !secret.MatchImage(d.Image) {
+			return fmt.Errorf("Secret %s is not allowed in %s", name, d.Image)
+		}
+		if expose(secret) {
+			d.Environment = append(d.Environment, name+"="+secret.Value)
+		}
+	}
+	for name, file := range d.SecretFiles {
+		file = path.Clean(file)
+		if !strings.HasPrefix(file, yaml.SecretDir+"/") {
+			return ErrSecretFile
+		}
+		secret, ok := secrets[name]
+		if !ok {
+			continue
+		}
+		if !secret.MatchImage(d.Image) {
+			return fmt.Errorf("Secret %s is not allowed in %s", name, d.Image)
+		}
+		if expose(secret) {
+			if d.Files == nil {
+				d.Files = map[string]string{}
+			}
+			d.Files[file] = secret.Value
+		}
+	}
+	return nil
+}
+
+func SecretFunc(secrets map[string]secure.Secret, event string, safe bool) RuleFunc {
+	return func(n Node) error {
+		return Secret(n, secrets, event, safe)
+	}
+}
+
 // expandImage expands an alias plugin name to use a
 // fully qualified image name.
 func expandImage(image string) string {
@@ -1 +1,102 @@
 package parser
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/yaml/secure"
+	"github.com/franela/goblin"
+)
+
+func Test_Funcs(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Secret rule", func() {
+
+		secrets := map[string]secure.Secret{
+			"DOCKER_PASSWORD": {
+				Value: "correct-horse",
+				Scope: secure.Scope{
+					Images: []string{"plugins/drone-docker"},
+					Events: []string{"push", "tag"},
+				},
+			},
+		}
+
+		g.It("Should add requested secrets to the environment", func() {
+			node := &DockerNode{
+				NodeType: NodePublish,
+				Image:    "plugins/drone-docker:latest",
+				Secrets:  []string{"DOCKER_PASSWORD", "UNDEFINED"},
+			}
+			err := Secret(node, secrets, "push", false)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(node.Environment).Equal([]string{"DOCKER_PASSWORD=correct-horse"})
+		})
+
+		g.It("Should not add requested secrets to the build in safe mode", func() {
+			secrets := map[string]secure.Secret{"TOKEN": {Value: "FOO"}}
+			node := &DockerNode{
+				NodeType: NodeBuild,
+				Image:    "golang:1.5",
+				Secrets:  []string{"TOKEN"},
+			}
+			err := Secret(node, secrets, "pull_request", true)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(node.Environment)).Equal(0)
+		})
+
+		g.It("Should reject requested secrets in other steps", func() {
+			node := &DockerNode{
+				NodeType: NodeBuild,
+				Image:    "golang:1.5",
+				Secrets:  []string{"DOCKER_PASSWORD"},
+			}
+			err := Secret(node, secrets, "push", false)
+			g.Assert(err != nil).IsTrue()
+			g.Assert(len(node.Environment)).Equal(0)
+		})
+
+		g.It("Should add requested secret files", func() {
+			node := &DockerNode{
+				NodeType:    NodePublish,
+				Image:       "plugins/drone-docker:latest",
+				SecretFiles: map[string]string{"DOCKER_PASSWORD": "/run/secrets/../secrets/password"},
+			}
+			err := Secret(node, secrets, "push", false)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(node.Files).Equal(map[string]string{"/run/secrets/password": "correct-horse"})
+		})
+
+		g.It("Should reject secret files outside the secrets directory", func() {
+			for _, file := range []string{"/drone/src/password", "/etc/password", "/run/secrets", "/run/secrets/../password", "run/secrets/password", "/"} {
+				node := &DockerNode{
+					NodeType:    NodePublish,
+					Image:       "plugins/drone-docker:latest",
+					SecretFiles: map[string]string{"DOCKER_PASSWORD": file},
+				}
+				g.Assert(Secret(node, secrets, "push", false)).Equal(ErrSecretFile)
+			}
+		})
+	})
+
+	g.Describe("Cache rule", func() {
+
+		g.It("Should mount the cache directory of the root", func() {
+			node := &DockerNode{NodeType: NodeCache}
+			Cache(node, "/mnt/cache", "octocat/hello-world/master", false)
+			g.Assert(node.Volumes).Equal([]string{"/mnt/cache/octocat/hello-world/master:/cache"})
+		})
+
+		g.It("Should mount the cache directory read-only", func() {
+			node := &DockerNode{NodeType: NodeCache}
+			Cache(node, "/var/lib/drone/cache", "octocat/hello-world/master", true)
+			g.Assert(node.Volumes).Equal([]string{"/var/lib/drone/cache/octocat/hello-world/master:/cache:ro"})
+		})
+
+		g.It("Should ignore other steps", func() {
+			node := &DockerNode{NodeType: NodeBuild}
+			Cache(node, "/var/lib/drone/cache", "octocat/hello-world/master", false)
+			g.Assert(len(node.Volumes)).Equal(0)
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/drone/drone-exec/yaml/inject"
+	"github.com/drone/drone-exec/yaml/secure"
+	"gopkg.in/yaml.v2"
+)
+
+// sections maps the sections of the Yaml file that define steps
+// to the node type of the steps. The cache, clone and build
+// sections define a single step, and the other sections define
+// a step for each key.
+var sections = map[string]NodeType{
+	"cache":   NodeCache,
+	"clone":   NodeClone,
+	"build":   NodeBuild,
+	"compose": NodeCompose,
+	"publish": NodePublish,
+	"deploy":  NodeDeploy,
+	"notify":  NodeNotify,
+}
+
+// InjectSecrets injects the secrets that are restricted to
+// matching images and events into the steps of the Yaml file,
+// and returns the resulting Yaml. The secrets are injected in the
+// same manner as other parameters, before the Yaml is resolved,
+// so that escaped parameters, and parameters in injected values,
+// are not expanded.
+//
+// An error is returned if the secret is referenced by a step
+// with an image that is not allowed, or by a field of the step
+// that is not injected. The secret is not exposed to builds
+// triggered by an event that is not allowed, or to the build
+// step when safe is true.
+func InjectSecrets(raw string, secrets map[string]secure.Secret, event string, safe bool) (string, error) {
+	if len(secrets) == 0 {
+		return raw, nil
+	}
+
+	// the yaml is left unchanged if it cannot be parsed, in
+	// which case the error is reported when parsing the steps.
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal([]byte(raw), &doc)
+	if err != nil {
+		return raw, nil
+	}
+	for _, item := range doc {
+		key, _ := item.Key.(string)
+		typ, ok := sections[key]
+		if !ok {
+			continue
+		}
+		steps, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		switch typ {
+		case NodeCache, NodeClone, NodeBuild:
+			err = injectStep(typ, "", steps, secrets, event, safe)
+			if err != nil {
+				return raw, err
+			}
+			continue
+		}
+		for _, step := range steps {
+			name, _ := step.Key.(string)
+			fields, ok := step.Value.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+			err = injectStep(typ, name, fields, secrets, event, safe)
+			if err != nil {
+				return raw, err
+			}
+		}
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return raw, err
+	}
+	return string(out), nil
+}
+
+// injectStep injects the secrets into the fields of the step,
+// where the step image defaults to the name of the step.
+func injectStep(typ NodeType, name string, fields yaml.MapSlice, secrets map[string]secure.Secret, event string, safe bool) error {
+	node := &DockerNode{NodeType: typ, Image: name}
+	for _, field := range fields {
+		if image, ok := field.Value.(string); ok && field.Key == "image" && len(image) != 0 {
+			node.Image = image
+		}
+	}
+	// a missing image is reported when parsing the steps.
+	ImageName(node)
+
+	params := map[string]string{}
+	for _, field := range fields {
+		key, _ := field.Key.(string)
+		for _, ref := range inject.ValueRefs(field.Value) {
+			secret, ok := secrets[ref.Name]
+			if !ok {
+				continue
+			}
+			if !injectField(typ, key) {
+				return fmt.Errorf("Secret %s is not allowed in the %s", ref.Name, key)
+			}
+			if !secret.MatchImage(node.Image) {
+				return fmt.Errorf("Secret %s is not allowed in %s", ref.Name, node.Image)
+			}
+			params[ref.Name] = ""
+			if secret.MatchEvent(event) && !(safe && typ == NodeBuild) {
+				params[ref.Name] = secret.Value
+			}
+		}
+	}
+	for i, field := range fields {
+		key, _ := field.Key.(string)
+		if injectField(typ, key) {
+			fields[i].Value = inject.InjectValue(field.Value, params)
+		}
+	}
+	return nil
+}
+
+// injectField is a helper function that returns true if secrets
+// are injected into the field of a step, which are the command
+// and environment of the step, and the plugin arguments.
+func injectField(typ NodeType, key string) bool {
+	switch key {
+	case "environment", "entrypoint", "command", "commands":
+		return true
+	case "image", "pull", "privileged", "volumes", "extra_hosts", "net", "reports", "secrets", "secret_files", "when":
+		return false
+	case "key", "fallback_keys", "paths":
+		return typ != NodeCache
+	}
+	return typ != NodeBuild && typ != NodeCompose
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/yaml/inject"
+	"github.com/drone/drone-exec/yaml/secure"
+	"github.com/franela/goblin"
+)
+
+func Test_Inject(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Secret injection", func() {
+
+		secrets := map[string]secure.Secret{
+			"DOCKER_PASSWORD": {
+				Value: "correct-horse",
+				Scope: secure.Scope{
+					Images: []string{"plugins/drone-docker"},
+					Events: []string{"push", "tag"},
+				},
+			},
+			"SLACK_TOKEN": {Value: "battery-staple"},
+		}
+
+		g.It("Should inject secrets into matching steps", func() {
+			tree, err := injectParse(dockerYaml, nil, secrets, "push")
+			g.Assert(err == nil).IsTrue()
+			node := findNode(tree, NodePublish)
+			g.Assert(node.Vargs["password"]).Equal("correct-horse")
+			g.Assert(node.Vargs["tags"]).Equal([]interface{}{"correct-horse"})
+		})
+
+		g.It("Should not expose secrets for other events", func() {
+			tree, err := injectParse(dockerYaml, nil, secrets, "pull_request")
+			g.Assert(err == nil).IsTrue()
+			node := findNode(tree, NodePublish)
+			g.Assert(node.Vargs["password"]).Equal("")
+		})
+
+		g.It("Should reject secrets in other steps", func() {
+			_, err := InjectSecrets(otherStepYaml, secrets, "push", false)
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should reject secrets in other fields", func() {
+			for _, raw := range otherFieldYaml {
+				_, err := InjectSecrets(raw, secrets, "push", false)
+				g.Assert(err != nil).IsTrue()
+			}
+		})
+
+		g.It("Should ignore other parameters", func() {
+			tree, err := injectParse(greetingYaml, nil, secrets, "push")
+			g.Assert(err == nil).IsTrue()
+			node := findNode(tree, NodeBuild)
+			g.Assert(node.Commands[0]).Equal("echo $$GREETING")
+			g.Assert(node.Volumes[0]).Equal("/tmp/$$GREETING:/tmp")
+		})
+
+		g.It("Should not expand escaped secrets", func() {
+			tree, err := injectParse(escapedYaml, nil, secrets, "push")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(findNode(tree, NodeBuild).Commands[0]).Equal("echo $$DOCKER_PASSWORD")
+			g.Assert(findNode(tree, NodeNotify).Vargs["message"]).Equal("token $$SLACK_TOKEN")
+		})
+
+		g.It("Should not expand secrets in injected values", func() {
+			params := map[string]string{"BRANCH": "x$$SLACK_TOKEN"}
+			tree, err := injectParse(branchYaml, params, secrets, "push")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(findNode(tree, NodeNotify).Vargs["message"]).Equal("branch x$$SLACK_TOKEN")
+		})
+
+		g.It("Should not alter secrets containing parameters", func() {
+			secrets := map[string]secure.Secret{"SLACK_TOKEN": {Value: "$$$$BRANCH"}}
+			params := map[string]string{"BRANCH": "master"}
+			tree, err := injectParse(branchYaml, params, secrets, "push")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(findNode(tree, NodeNotify).Vargs["token"]).Equal("$$$$BRANCH")
+		})
+	})
+}
+
+// injectParse is a helper function that injects the parameters
+// and secrets into the yaml, in the same order as a build, and
+// returns the parsed tree.
+func injectParse(raw string, params map[string]string, secrets map[string]secure.Secret, event string) (*Tree, error) {
+	raw, _ = inject.Inject(raw, params)
+	raw, err := InjectSecrets(raw, secrets, event, event == "pull_request")
+	if err != nil {
+		return nil, err
+	}
+	raw, _ = inject.Resolve(raw)
+	return Parse(raw, []RuleFunc{ImageName})
+}
+
+// findNode is a helper function that returns the first Docker
+// node of the node type in the tree.
+func findNode(tree *Tree, typ NodeType) *DockerNode {
+	for _, node := range tree.Root.Nodes {
+		if node, ok := node.(*FilterNode).Node.(*DockerNode); ok && node.NodeType == typ {
+			return node
+		}
+	}
+	return nil
+}
+
+var dockerYaml = `
+publish:
+  docker:
+    password: $$DOCKER_PASSWORD
+    tags: [ "$${DOCKER_PASSWORD}" ]
+`
+
+var otherStepYaml = `
+build:
+  image: golang:1.5
+  commands:
+    - echo $$DOCKER_PASSWORD
+`
+
+var otherFieldYaml = []string{`
+publish:
+  docker:
+    volumes:
+      - /tmp/$$DOCKER_PASSWORD:/tmp
+`, `
+publish:
+  docker:
+    extra_hosts: [ "$${DOCKER_PASSWORD}:127.0.0.1" ]
+`, `
+publish:
+  docker:
+    net: $$DOCKER_PASSWORD
+`, `
+notify:
+  slack:
+    image: $$SLACK_TOKEN
+`}
+
+var greetingYaml = `
+build:
+  image: golang:1.5
+  volumes:
+    - /tmp/$$GREETING:/tmp
+  commands:
+    - echo $$GREETING
+`
+
+var escapedYaml = `
+build:
+  image: golang:1.5
+  commands:
+    - echo $$$$DOCKER_PASSWORD
+notify:
+  slack:
+    message: token $$$$SLACK_TOKEN
+`
+
+var branchYaml = `
+notify:
+  slack:
+    token: $$SLACK_TOKEN
+    message: branch $$BRANCH
+`
@@ -1,6 +1,12 @@
 package parser
 
-import "github.com/drone/drone-exec/yaml"
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/drone/drone-exec/yaml/expr"
+)
 
 // NodeType identifies the type of a parse tree node.
 type NodeType uint
@@ -51,15 +57,20 @@ type DockerNode struct {
 	NodeType
 
 	Image       string
-	Pull        bool
+	Pull        yaml.PullPolicy
+	Pin         bool
 	Privileged  bool
 	Environment []string
 	Entrypoint  []string
 	Command     []string
 	Commands    []string
+	JUnit       []string
 	Volumes     []string
 	ExtraHosts  []string
 	Net         string
+	Secrets     []string
+	SecretFiles map[string]string // secret names mapped to file paths
+	Files       map[string]string // file paths mapped to contents
 	Vargs       map[string]interface{}
 }
 
@@ -75,6 +86,8 @@ func newDockerNode(typ NodeType, c yaml.Container) *DockerNode {
 		Volumes:     c.Volumes,
 		ExtraHosts:  c.ExtraHosts,
 		Net:         c.Net,
+		Secrets:     c.Secrets.Slice(),
+		SecretFiles: c.SecretFiles,
 	}
 }
 
@@ -87,35 +100,120 @@ func newPluginNode(typ NodeType, p yaml.Plugin) *DockerNode {
 func newBuildNode(typ NodeType, b yaml.Build) *DockerNode {
 	node := newDockerNode(typ, b.Container)
 	node.Commands = b.Commands
+	node.JUnit = b.Reports.JUnit.Slice()
 	return node
 }
 
+// Build status values used to filter nodes.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusChanged = "changed"
+	StatusKilled  = "killed"
+)
+
 // FilterNode represents a conditional step used to
 // filter nodes. If conditions are met the child
 // node is executed.
 type FilterNode struct {
 	NodeType
 
-	Repo    string
-	Branch  []string
-	Event   []string
-	Success string
-	Failure string
-	Change  string
-	Matrix  map[string]string
+	Repo   yaml.Constraint
+	Branch yaml.Constraint
+	Event  yaml.Constraint
+	Tag    yaml.Constraint
+	Status []string
+	Matrix map[string]yaml.Constraint
+	Path   yaml.Constraint
+	Expr   *expr.Expr
 
 	Node Node // Node to execution if conditions met
 }
 
-func newFilterNode(p yaml.Plugin) *FilterNode {
-	return &FilterNode{
+// NewFilterNode returns a FilterNode for the filter
+// conditions, without a child node. This is used to
+// evaluate conditions of sections that are not steps.
+// An error is returned if the filter expression is
+// invalid.
+func NewFilterNode(f yaml.Filter) (*FilterNode, error) {
+	node := &FilterNode{
 		NodeType: NodeFilter,
-		Repo:     p.Filter.Repo,
-		Branch:   p.Filter.Branch.Slice(),
-		Event:    p.Filter.Event.Slice(),
-		Matrix:   p.Filter.Matrix,
-		Success:  p.Filter.Success,
-		Failure:  p.Filter.Failure,
-		Change:   p.Filter.Change,
+		Repo:     f.Repo,
+		Branch:   f.Branch,
+		Event:    f.Event,
+		Tag:      f.Tag,
+		Status:   f.Status.Slice(),
+		Matrix:   f.Matrix,
+		Path:     f.Path,
+	}
+	for _, status := range node.Status {
+		switch status {
+		case StatusSuccess, StatusFailure, StatusChanged, StatusKilled:
+		default:
+			return nil, fmt.Errorf("Invalid when status %q", status)
+		}
+	}
+	if len(node.Status) == 0 {
+		node.Status = toStatus(f)
+	}
+	if len(f.Expr) != 0 {
+		e, err := expr.Parse(f.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid when expression %q. %s", f.Expr, err)
+		}
+		node.Expr = e
+	}
+	return node, nil
+}
+
+// toStatus is a helper function that maps the deprecated
+// success, failure and change filters to the build status
+// values. Filters set to true are included. If none are
+// true, the status values of filters set to false are
+// excluded.
+func toStatus(f yaml.Filter) []string {
+	var status []string
+	var exclude = map[string]bool{}
+	for _, filter := range []struct {
+		name   string
+		value  string
+		status string
+	}{
+		{"success", f.Success, StatusSuccess},
+		{"failure", f.Failure, StatusFailure},
+		{"change", f.Change, StatusChanged},
+	} {
+		if len(filter.value) == 0 {
+			continue
+		}
+		log.Warnf("Yaml when.%s is deprecated, use when.status instead", filter.name)
+		ok, err := parseBool(filter.value)
+		switch {
+		case err != nil:
+			log.Warnln(err)
+		case ok:
+			status = append(status, filter.status)
+		default:
+			exclude[filter.status] = true
+		}
+	}
+	if len(status) != 0 || len(exclude) == 0 {
+		return status
+	}
+	for _, s := range []string{StatusSuccess, StatusFailure} {
+		if !exclude[s] {
+			status = append(status, s)
+		}
+	}
+	return status
+}
+
+func parseBool(str string) (value bool, err error) {
+	switch str {
+	case "true", "TRUE", "True", "On", "ON", "on":
+		return true, nil
+	case "false", "FALSE", "False", "Off", "off", "OFF":
+		return false, nil
 	}
+	return false, fmt.Errorf("Error parsing boolean %s", str)
 }
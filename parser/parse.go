@@ -78,59 +78,94 @@ func Load(conf *yaml.Config, rules []RuleFunc) (*Tree, error) {
 	return tree, nil
 }
 
-func (t *Tree) appendPlugin(typ NodeType, plugins ...yaml.Plugin) error {
-	for _, plugin := range plugins {
-		node := newPluginNode(typ, plugin)
-		for _, rule := range t.rules {
-			err := rule(node)
+// Apply applies the rules to the Docker nodes of the node
+// types, for example to modify the nodes between runs.
+func (t *Tree) Apply(flags NodeType, rules ...RuleFunc) error {
+	return apply(t.Root, flags, rules)
+}
+
+func apply(node Node, flags NodeType, rules []RuleFunc) error {
+	switch node := node.(type) {
+	case *ListNode:
+		for _, node := range node.Nodes {
+			err := apply(node, flags, rules)
 			if err != nil {
 				return err
 			}
 		}
-		fnode := newFilterNode(plugin)
-		fnode.Node = node
-		// TODO: we should apply rules to all nodes in
-		// the tree AFTER the entire tree is constructed.
-		for _, rule := range t.rules {
-			err := rule(fnode)
+	case *FilterNode:
+		return apply(node.Node, flags, rules)
+	case *DockerNode:
+		if node.NodeType&flags == 0 {
+			return nil
+		}
+		for _, rule := range rules {
+			err := rule(node)
 			if err != nil {
 				return err
 			}
 		}
-		t.Root.append(fnode)
 	}
 	return nil
 }
 
-func (t *Tree) appendBuild(build yaml.Build) error {
-	node := newBuildNode(NodeBuild, build)
-	for _, rule := range t.rules {
-		err := rule(node)
+func (t *Tree) appendPlugin(typ NodeType, plugins ...yaml.Plugin) error {
+	for _, plugin := range plugins {
+		node := newPluginNode(typ, plugin)
+		err := t.appendFilter(node, plugin.Filter)
 		if err != nil {
 			return err
 		}
 	}
-	t.Root.append(node)
 	return nil
 }
 
-func (t *Tree) appendCache(cache yaml.Plugin) error {
+func (t *Tree) appendBuild(build yaml.Build) error {
+	node := newBuildNode(NodeBuild, build)
+	return t.appendFilter(node, build.Filter)
+}
+
+func (t *Tree) appendCache(cache yaml.Cache) error {
 	if len(cache.Vargs) == 0 {
 		return nil
 	}
-	return t.appendPlugin(NodeCache, cache)
+	return t.appendPlugin(NodeCache, cache.Plugin)
 }
 
-func (t *Tree) appendCompose(plugins []yaml.Container) error {
-	for _, plugin := range plugins {
-		node := newDockerNode(NodeCompose, plugin)
-		for _, rule := range t.rules {
-			err := rule(node)
-			if err != nil {
-				return err
-			}
+func (t *Tree) appendCompose(services []yaml.Service) error {
+	for _, service := range services {
+		node := newDockerNode(NodeCompose, service.Container)
+		err := t.appendFilter(node, service.Filter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFilter applies the rules to the node, and appends
+// the node wrapped in a filter node to the tree, so that
+// the node is only executed if the conditions are met.
+func (t *Tree) appendFilter(node *DockerNode, filter yaml.Filter) error {
+	for _, rule := range t.rules {
+		err := rule(node)
+		if err != nil {
+			return err
+		}
+	}
+	fnode, err := NewFilterNode(filter)
+	if err != nil {
+		return err
+	}
+	fnode.Node = node
+	// TODO: we should apply rules to all nodes in
+	// the tree AFTER the entire tree is constructed.
+	for _, rule := range t.rules {
+		err := rule(fnode)
+		if err != nil {
+			return err
 		}
-		t.Root.append(node)
 	}
+	t.Root.append(fnode)
 	return nil
 }
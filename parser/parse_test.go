@@ -1 +1,93 @@
 package parser
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/yaml"
+	"github.com/franela/goblin"
+)
+
+func TestParse(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Parse filters", func() {
+
+		g.It("Should parse the status filter", func() {
+			tree, err := Parse(statusYaml, nil)
+			g.Assert(err == nil).IsTrue()
+			node := tree.Root.Nodes[2].(*FilterNode)
+			g.Assert(node.Status).Equal([]string{"failure", "changed"})
+		})
+
+		g.It("Should error when the status is invalid", func() {
+			_, err := Parse(invalidStatusYaml, nil)
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should map the deprecated status filters", func() {
+			for _, test := range deprecatedTests {
+				node, err := NewFilterNode(test.filter)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(node.Status).Equal(test.status)
+			}
+		})
+
+		g.It("Should error when the expression is invalid", func() {
+			_, err := Parse(invalidExprYaml, nil)
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should apply rules to the node types", func() {
+			tree, err := Parse(cacheYaml, []RuleFunc{CacheFunc("/var/lib/drone/cache", "octocat/hello-world", true)})
+			g.Assert(err == nil).IsTrue()
+			err = tree.Apply(NodeCache, CacheFunc("/var/lib/drone/cache", "octocat/hello-world/master", false))
+			g.Assert(err == nil).IsTrue()
+			node := tree.Root.Nodes[0].(*FilterNode).Node.(*DockerNode)
+			g.Assert(node.Volumes).Equal([]string{"/var/lib/drone/cache/octocat/hello-world/master:/cache"})
+		})
+	})
+}
+
+var deprecatedTests = []struct {
+	filter yaml.Filter
+	status []string
+}{
+	{yaml.Filter{}, nil},
+	{yaml.Filter{Success: "true"}, []string{"success"}},
+	{yaml.Filter{Failure: "true", Change: "true"}, []string{"failure", "changed"}},
+	{yaml.Filter{Success: "false"}, []string{"failure"}},
+	{yaml.Filter{Success: "false", Failure: "true"}, []string{"failure"}},
+	{yaml.Filter{Change: "true", Success: "false", Failure: "false"}, []string{"changed"}},
+}
+
+var statusYaml = `
+build:
+  image: golang
+  commands: [ go test ]
+notify:
+  slack:
+    when:
+      status: [ failure, changed ]
+`
+
+var invalidStatusYaml = `
+notify:
+  slack:
+    when:
+      status: broken
+`
+
+var invalidExprYaml = `
+deploy:
+  heroku:
+    when:
+      expr: branch = "master"
+`
+
+var cacheYaml = `
+cache:
+  mount: [ node_modules ]
+build:
+  image: node
+  commands: [ npm install ]
+`
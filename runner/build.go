@@ -7,6 +7,7 @@ import (
 	"github.com/drone/drone-exec/docker"
 	"github.com/drone/drone-exec/parser"
 	"github.com/drone/drone-exec/runner/script"
+	"github.com/drone/drone-exec/yaml"
 	"github.com/samalba/dockerclient"
 )
 
@@ -19,8 +20,9 @@ const DefaultCloner = "plugins/drone-git"
 const DefaultCacher = "plugins/drone-cache"
 
 type Build struct {
-	tree  *parser.Tree
-	flags parser.NodeType
+	tree     *parser.Tree
+	flags    parser.NodeType
+	services []*dockerclient.ContainerInfo // services with secret files
 }
 
 func (b *Build) Run(state *State) error {
@@ -29,6 +31,16 @@ func (b *Build) Run(state *State) error {
 
 func (b *Build) RunNode(state *State, flags parser.NodeType) error {
 	b.flags = flags
+
+	// services with secret files are removed once the steps
+	// complete, so that the files are not retained until the
+	// build completes.
+	defer func() {
+		for _, info := range b.services {
+			docker.RemoveFiles(state.Client, info)
+		}
+		b.services = nil
+	}()
 	return b.walk(b.tree.Root, state)
 }
 
@@ -66,7 +78,7 @@ func (b *Build) walk(node parser.Node, state *State) (err error) {
 			// conf := toContainerConfig(node)
 			// conf.Cmd = toCommand(state, node)
 			// conf.Image = "plugins/drone-build"
-			// info, err := docker.Run(state.Client, conf, node.Pull)
+			// info, err := docker.Run(state.Client, conf, node.Pull, node.Pin)
 			// if err != nil {
 			// 	state.Exit(255)
 			// } else if info.State.ExitCode != 0 {
@@ -88,35 +100,81 @@ func (b *Build) walk(node parser.Node, state *State) (err error) {
 				script.Encode(nil, conf, node)
 			}
 
-			info, err := docker.Run(state.Client, conf, node.Pull)
+			info, err := docker.RunFiles(state.Client, conf, node.Pull, node.Pin, node.Files)
 			if err != nil {
 				state.Exit(255)
 			} else if info.State.ExitCode != 0 {
 				state.Exit(info.State.ExitCode)
 			}
+			removeFiles(state, node, info)
+
+			// test reports are parsed for failed builds
+			// as well, to list the failed tests.
+			if len(node.JUnit) != 0 {
+				collectReports(state, node)
+			}
 
 		case parser.NodeCompose:
 			conf := toContainerConfig(node)
-			_, err := docker.Start(state.Client, conf, node.Pull)
+			info, err := docker.StartFiles(state.Client, conf, node.Pull, node.Pin, node.Files)
 			if err != nil {
 				state.Exit(255)
+			} else if len(node.Files) != 0 {
+				b.services = append(b.services, info)
 			}
 
 		default:
 			conf := toContainerConfig(node)
 			conf.Cmd = toCommand(state, node)
-			info, err := docker.Run(state.Client, conf, node.Pull)
+			info, err := docker.RunFiles(state.Client, conf, node.Pull, node.Pin, node.Files)
 			if err != nil {
 				state.Exit(255)
 			} else if info.State.ExitCode != 0 {
 				state.Exit(info.State.ExitCode)
 			}
+			removeFiles(state, node, info)
 		}
 	}
 
 	return nil
 }
 
+// Images returns the distinct images of the steps that may
+// be executed for the node types, along with the image pull
+// policy. When steps share an image the strongest policy is
+// returned. Steps excluded by their filters are skipped.
+func (b *Build) Images(state *State, flags parser.NodeType) map[string]yaml.PullPolicy {
+	images := map[string]yaml.PullPolicy{}
+	b.images(b.tree.Root, state, flags, images)
+	return images
+}
+
+func (b *Build) images(node parser.Node, state *State, flags parser.NodeType, images map[string]yaml.PullPolicy) {
+	switch node := node.(type) {
+	case *parser.ListNode:
+		for _, node := range node.Nodes {
+			b.images(node, state, flags, images)
+		}
+
+	case *parser.FilterNode:
+		if mayMatch(node, state) {
+			b.images(node.Node, state, flags, images)
+		}
+
+	case *parser.DockerNode:
+		if shouldSkip(flags, node.NodeType) || len(node.Image) == 0 {
+			break
+		}
+		pull, ok := images[node.Image]
+		switch {
+		case !ok, node.Pull == yaml.PullAlways:
+			images[node.Image] = node.Pull
+		case pull == yaml.PullNever:
+			images[node.Image] = node.Pull
+		}
+	}
+}
+
 func expectMatch() {
 
 }
@@ -148,3 +206,14 @@ func shouldEscalate(node *parser.DockerNode) bool {
 	return node.Image == "plugins/drone-docker" ||
 		node.Image == "plugins/drone-gcr"
 }
+
+// removeFiles is a helper function that removes the container
+// of the step once completed, along with the secret files, if
+// secret files were written for the container, so that the files
+// are not retained until the build completes.
+func removeFiles(state *State, node *parser.DockerNode, info *dockerclient.ContainerInfo) {
+	if len(node.Files) == 0 || info == nil {
+		return
+	}
+	docker.RemoveFiles(state.Client, info)
+}
@@ -1 +1,85 @@
 package runner
+
+import (
+	"testing"
+
+	"github.com/drone/drone-exec/parser"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/drone/drone-plugin-go/plugin"
+	"github.com/franela/goblin"
+)
+
+func TestBuild(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Build images", func() {
+
+		tree, err := parser.Parse(sampleYaml, []parser.RuleFunc{parser.ImageName})
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		state := &State{
+			Repo:  &plugin.Repo{FullName: "octocat/hello-world"},
+			Build: &plugin.Build{Branch: "master", Event: plugin.EventPush},
+			Job:   &plugin.Job{},
+		}
+
+		g.It("Should collect distinct images", func() {
+			images := Load(tree).Images(state, parser.NodeBuild|parser.NodeCompose|parser.NodeDeploy)
+			g.Assert(len(images)).Equal(3)
+			g.Assert(images["golang:1.5"]).Equal(yaml.PullAlways)
+			g.Assert(images["redis:2.8"]).Equal(yaml.PullNever)
+			g.Assert(images["plugins/drone-heroku:latest"]).Equal(yaml.PullPolicy(""))
+		})
+
+		g.It("Should skip images of excluded node types", func() {
+			images := Load(tree).Images(state, parser.NodeClone)
+			g.Assert(len(images)).Equal(1)
+			g.Assert(images["plugins/drone-git:latest"]).Equal(yaml.PullPolicy(""))
+		})
+
+		g.It("Should skip images of filtered services", func() {
+			state.Job.Environment = map[string]string{"SUITE": "integration"}
+			images := Load(tree).Images(state, parser.NodeCompose)
+			g.Assert(len(images)).Equal(3)
+			g.Assert(images["elasticsearch:2.2"]).Equal(yaml.PullPolicy(""))
+
+			state.Job.Environment = map[string]string{"SUITE": "unit"}
+			images = Load(tree).Images(state, parser.NodeCompose)
+			g.Assert(len(images)).Equal(2)
+		})
+
+		g.It("Should skip images of filtered steps", func() {
+			state.Build.Branch = "develop"
+			images := Load(tree).Images(state, parser.NodeDeploy)
+			g.Assert(len(images)).Equal(0)
+		})
+	})
+}
+
+var sampleYaml = `
+build:
+  image: golang:1.5
+  commands:
+    - go build
+
+compose:
+  redis:
+    image: redis:2.8
+    pull: never
+  database:
+    image: golang:1.5
+    pull: always
+  elasticsearch:
+    image: elasticsearch:2.2
+    when:
+      matrix:
+        SUITE: integration
+
+deploy:
+  heroku:
+    app: foo.com
+    when:
+      branch: master
+`
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/drone/drone-exec/docker"
+	"github.com/drone/drone-exec/parser"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/samalba/dockerclient"
+)
+
+// DefaultGitImage is the image used to compute the files
+// changed by the build.
+const DefaultGitImage = DefaultCloner
+
+// Changes returns the files changed between the commit of
+// the previous build and the commit of the current build,
+// by executing git in the cloned workspace. If the commit of
+// the previous build is not in a shallow clone it is fetched
+// before computing the changed files.
+func Changes(state *State) ([]string, error) {
+	if state.BuildLast == nil || len(state.BuildLast.Commit) == 0 {
+		return nil, fmt.Errorf("No previous build commit")
+	}
+	base := state.BuildLast.Commit
+
+	code, _, err := git(state, "cat-file", "-e", base+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		code, _, err = git(state, "fetch", "--depth=1", "origin", base)
+		if err != nil {
+			return nil, err
+		}
+		if code != 0 {
+			return nil, fmt.Errorf("Error fetching previous build commit %s, git exited with %d", base, code)
+		}
+	}
+
+	code, out, err := git(state, "diff", "--name-only", base, state.Build.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("Error computing changed files, git exited with %d", code)
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if file := strings.TrimSpace(scanner.Text()); len(file) != 0 {
+			files = append(files, file)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// git is a helper function that executes the git command in
+// the cloned workspace, and returns the exit code and output.
+func git(state *State, args ...string) (int, []byte, error) {
+	conf := &dockerclient.ContainerConfig{
+		Image:      DefaultGitImage,
+		Entrypoint: []string{"git"},
+		Cmd:        args,
+		WorkingDir: state.Workspace.Path,
+		HostConfig: dockerclient.HostConfig{
+			MemorySwappiness: -1,
+		},
+	}
+	info, out, err := docker.Output(state.Client, conf, yaml.PullIfNotPresent)
+	if err != nil {
+		return 0, nil, err
+	}
+	return info.State.ExitCode, out, nil
+}
+
+// HasPathFilter returns true if the execution of any step
+// depends on the files changed by the build.
+func (b *Build) HasPathFilter() bool {
+	for _, node := range b.tree.Root.Nodes {
+		f, ok := node.(*parser.FilterNode)
+		if ok && f.Path.Len() != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/drone/drone-exec/junit"
 	"github.com/drone/drone-plugin-go/plugin"
 	"github.com/samalba/dockerclient"
 )
@@ -23,6 +24,14 @@ type State struct {
 	// used to spawn container tasks.
 	Client dockerclient.Client
 
+	// Changes holds the files changed by the build,
+	// or nil if the changed files are unknown.
+	Changes []string
+
+	// Tests holds the results of the test reports
+	// produced by the build steps.
+	Tests *junit.Report
+
 	Stdout, Stderr io.Writer
 }
 
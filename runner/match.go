@@ -1,14 +1,23 @@
 package runner
 
 import (
-	"fmt"
-	"path"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/drone/drone-exec/glob"
 	"github.com/drone/drone-exec/parser"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/drone/drone-exec/yaml/expr"
 	"github.com/drone/drone-plugin-go/plugin"
 )
 
+// Match returns true if the filter conditions are
+// matched by the current state of the build.
+func Match(node *parser.FilterNode, s *State) bool {
+	return isMatch(node, s)
+}
+
 // isMatch is a helper function that returns true if
 // all criteria is matched.
 func isMatch(node *parser.FilterNode, s *State) (match bool) {
@@ -18,139 +27,186 @@ func isMatch(node *parser.FilterNode, s *State) (match bool) {
 		last = s.BuildLast.Status
 	}
 
+	if !mayMatch(node, s) {
+		return false
+	}
+	if !matchChanges(node.Path, s.Changes) {
+		return false
+	}
+	if node.Expr != nil && !node.Expr.Eval(toVars(s)) {
+		return false
+	}
+
+	return matchStatus(node.Status, s.Job.Status, last)
+}
+
+// mayMatch is a helper function that returns true if all
+// criteria known before execution is matched. The build
+// status criteria is not evaluated.
+func mayMatch(node *parser.FilterNode, s *State) bool {
 	switch {
 	case !matchBranch(node.Branch, s.Build.Branch):
 		return false
 	case !matchMatrix(node.Matrix, s.Job.Environment):
 		return false
-	case !matchRepo(node.Repo, s.Repo.FullName):
+	case !matchConstraint(node.Repo, s.Repo.FullName):
 		return false
-	case !matchEvent(node.Event, s.Build.Event):
+	case !matchConstraint(node.Event, s.Build.Event):
+		return false
+	case !matchTag(node.Tag, s.Build):
 		return false
 	}
-
-	switch {
-	case matchSuccess(node.Success, s.Job.Status):
-		return true
-	case matchFailure(node.Failure, s.Job.Status):
-		return true
-	case matchChange(node.Change, s.Job.Status, last):
-		return true
-	}
-
-	return false
+	return true
 }
 
 // matchBranch is a helper function that returns true
-// if all_branches is true. Else it returns false if a
-// branch condition is specified, and the branch does
-// not match.
-func matchBranch(want []string, got string) (match bool) {
-	if len(want) == 0 {
+// if the branch, without the refs/heads/ prefix, matches
+// the constraint.
+func matchBranch(want yaml.Constraint, got string) bool {
+	return matchConstraint(want, strings.TrimPrefix(got, "refs/heads/"))
+}
+
+// matchTag is a helper function that returns true if no
+// tag constraint is specified. Else it returns false if
+// the build is not a tag, or the tag does not match the
+// constraint.
+func matchTag(want yaml.Constraint, build *plugin.Build) bool {
+	if want.Len() == 0 {
 		return true
 	}
-	if strings.HasPrefix(got, "refs/heads/") {
-		got = got[11:]
-	}
-	for _, pattern := range want {
-		if matchPath(pattern, got) {
-			match = true
-			break
-		}
+	if build.Event != plugin.EventTag {
+		return false
 	}
-	return
+	return matchConstraint(want, strings.TrimPrefix(build.Ref, "refs/tags/"))
 }
 
-// matchRepo is a helper function that returns false
-// if this task is only intended for a named repo,
-// the current build does not match that repo.
-//
-// This is useful when you want to prevent forks from
-// executing deployment, publish or notification steps.
-func matchRepo(want, got string) bool {
-	if len(want) == 0 {
-		return true
+// matchMatrix is a helper function that returns false
+// to limit steps to only certain matrix axis.
+func matchMatrix(want map[string]yaml.Constraint, got map[string]string) bool {
+	for k, v := range want {
+		if !matchConstraint(v, got[k]) {
+			return false
+		}
 	}
-	return got == want
+	return true
 }
 
-// matchEvent is a helper function that returns false
-// if this task is only intended for a specific repository
-// event not matched by the current build. For example,
-// only executing a build for `tags` or `pull_requests`
-func matchEvent(want []string, got string) (match bool) {
-	if len(want) == 0 {
+// matchChanges is a helper function that returns true if
+// any of the changed files match the path constraint. If
+// the changed files are unknown this returns true.
+func matchChanges(want yaml.Constraint, changes []string) bool {
+	if want.Len() == 0 || changes == nil {
 		return true
 	}
-	for _, want_ := range want {
-		if got == want_ {
-			match = true
-			break
+	for _, file := range changes {
+		if matchConstraint(want, file) {
+			return true
 		}
 	}
-	return
+	return false
 }
 
-// matchMatrix is a helper function that returns false
-// to limit steps to only certain matrix axis.
-func matchMatrix(want, got map[string]string) bool {
-	if len(want) == 0 {
+// matchConstraint is a helper function that returns false
+// if the value matches an excluded pattern, or included
+// patterns are specified and the value matches none.
+//
+// This is used to limit steps to certain branches, tags,
+// events and matrix axis, or to a named repository, which
+// is useful to prevent forks from executing deployment,
+// publish or notification steps.
+func matchConstraint(want yaml.Constraint, got string) bool {
+	for _, pattern := range want.Exclude {
+		if matchPattern(pattern, got) {
+			return false
+		}
+	}
+	if len(want.Include) == 0 {
 		return true
 	}
-	for k, v := range want {
-		if got[k] != v {
-			return false
+	for _, pattern := range want.Include {
+		if matchPattern(pattern, got) {
+			return true
 		}
 	}
-	return true
+	return false
 }
 
-func matchSuccess(toggle, status string) bool {
-	ok, err := parseBool(toggle)
-	if err != nil {
-		return true
+// toVars is a helper function that returns the build
+// metadata available to filter expressions.
+func toVars(s *State) expr.Vars {
+	status := s.Job.Status
+	if status == plugin.StateRunning {
+		status = plugin.StateSuccess
+	}
+	vars := expr.Vars{
+		"author": s.Build.Author,
+		"branch": strings.TrimPrefix(s.Build.Branch, "refs/heads/"),
+		"commit": s.Build.Commit,
+		"event":  s.Build.Event,
+		"fork":   strconv.FormatBool(isFork(s)),
+		"ref":    s.Build.Ref,
+		"repo":   s.Repo.FullName,
+		"status": status,
+	}
+	if s.Build.Event == plugin.EventTag {
+		vars["tag"] = strings.TrimPrefix(s.Build.Ref, "refs/tags/")
+	}
+	for _, env := range toEnv(s) {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			vars["env."+parts[0]] = parts[1]
+		}
+	}
+	for k, v := range s.Job.Environment {
+		vars["matrix."+k] = v
 	}
-	return ok && (status == plugin.StateSuccess || status == plugin.StateRunning)
+	return vars
 }
 
-func matchFailure(toggle, status string) bool {
-	ok, err := parseBool(toggle)
-	if err != nil {
-		return true
-	}
-	return ok && status != plugin.StateSuccess && status != plugin.StateRunning
+// isFork is a helper function that returns true if the
+// build is a pull request from a forked repository.
+func isFork(s *State) bool {
+	return s.Build.Event == plugin.EventPull &&
+		len(s.Build.Remote) != 0 &&
+		s.Build.Remote != s.Repo.Clone
 }
 
-func matchChange(toggle, status, last string) bool {
-	ok, err := parseBool(toggle)
-	if err != nil {
+// matchStatus is a helper function that returns true if
+// no status is specified, or the build status matches one
+// of the status values. A running build is considered
+// successful.
+func matchStatus(want []string, status, last string) bool {
+	if len(want) == 0 {
 		return true
 	}
-	switch status {
-	case plugin.StateRunning:
+	if status == plugin.StateRunning {
 		status = plugin.StateSuccess
 	}
-	return ok && status != last
-}
-
-func parseBool(str string) (value bool, err error) {
-	switch str {
-	case "true", "TRUE", "True", "On", "ON", "on":
-		return true, nil
-	case "false", "FALSE", "False", "Off", "off", "OFF":
-		return false, nil
+	for _, w := range want {
+		switch {
+		case w == parser.StatusSuccess && status == plugin.StateSuccess:
+			return true
+		case w == parser.StatusFailure && (status == plugin.StateFailure || status == plugin.StateError):
+			return true
+		case w == parser.StatusKilled && status == plugin.StateKilled:
+			return true
+		case w == parser.StatusChanged && status != last:
+			return true
+		}
 	}
-	return false, fmt.Errorf("Error parsing boolean %s", str)
+	return false
 }
 
-func matchPath(pattern, str string) bool {
-	negate := strings.HasPrefix(pattern, "!")
-	if negate {
-		pattern = pattern[1:]
-	}
-	match, _ := path.Match(pattern, str)
-	if negate {
-		match = !match
+// matchPattern is a helper function that returns true if
+// the value matches the glob pattern. A pattern enclosed in
+// slashes is matched as a regular expression.
+func matchPattern(pattern, str string) bool {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false
+		}
+		return re.MatchString(str)
 	}
-	return match
+	return glob.Match(pattern, str)
 }
@@ -3,6 +3,10 @@ package runner
 import (
 	"testing"
 
+	"github.com/drone/drone-exec/parser"
+	"github.com/drone/drone-exec/yaml"
+	"github.com/drone/drone-exec/yaml/expr"
+	"github.com/drone/drone-plugin-go/plugin"
 	"github.com/franela/goblin"
 )
 
@@ -11,59 +15,152 @@ func TestParse(t *testing.T) {
 	g := goblin.Goblin(t)
 	g.Describe("Yaml conditions", func() {
 
-		g.It("Should match a branch", func() {
-			g.Assert(matchBranch([]string{"master"}, "master")).Equal(true)
+		g.It("Should match constraints", func() {
+			for _, test := range constraintTests {
+				g.Assert(matchConstraint(test.want, test.got)).Equal(test.match)
+			}
 		})
 
-		g.It("Should match a branch wildcard", func() {
-			g.Assert(matchBranch([]string{"*"}, "master")).Equal(true)
+		g.It("Should match a branch", func() {
+			g.Assert(matchBranch(include("master"), "refs/heads/master")).Equal(true)
+			g.Assert(matchBranch(include("dev"), "refs/heads/master")).Equal(false)
 		})
 
-		g.It("Should match a branch with negation", func() {
-			g.Assert(matchBranch([]string{"!dev"}, "master")).Equal(true)
+		g.It("Should match a tag", func() {
+			tag := &plugin.Build{Event: plugin.EventTag, Ref: "refs/tags/v1.0.0"}
+			push := &plugin.Build{Event: plugin.EventPush, Ref: "refs/heads/master"}
+			g.Assert(matchTag(yaml.Constraint{}, push)).Equal(true)
+			g.Assert(matchTag(include("v*"), tag)).Equal(true)
+			g.Assert(matchTag(include("v2.*"), tag)).Equal(false)
+			g.Assert(matchTag(exclude("*-rc*"), tag)).Equal(true)
+			g.Assert(matchTag(include("v*"), push)).Equal(false)
 		})
 
-		g.It("Should match when branch slice is empty", func() {
-			g.Assert(matchBranch([]string{}, "master")).Equal(true)
+		g.It("Should match a matrix axis", func() {
+			env := map[string]string{"GO_VERSION": "1.5", "DATABASE": "mysql"}
+			g.Assert(matchMatrix(nil, env)).Equal(true)
+			g.Assert(matchMatrix(map[string]yaml.Constraint{"GO_VERSION": include("1.*")}, env)).Equal(true)
+			g.Assert(matchMatrix(map[string]yaml.Constraint{"DATABASE": exclude("mysql")}, env)).Equal(false)
+			g.Assert(matchMatrix(map[string]yaml.Constraint{"GO_VERSION": include("1.5"), "DATABASE": include("postgres")}, env)).Equal(false)
 		})
 
-		g.It("Should match when branch matches one of", func() {
-			g.Assert(matchBranch([]string{"dev", "master"}, "master")).Equal(true)
+		g.It("Should notify on change", func() {
+			g.Assert(matchStatus([]string{"changed"}, "success", "failure")).Equal(true)
+			g.Assert(matchStatus([]string{"changed"}, "running", "failure")).Equal(true)
 		})
 
-		g.It("Should not match a branch", func() {
-			g.Assert(matchBranch([]string{"dev"}, "master")).Equal(false)
+		g.It("Should not notify on change when no change", func() {
+			g.Assert(matchStatus([]string{"changed"}, "success", "success")).Equal(false)
+			g.Assert(matchStatus([]string{"changed"}, "running", "success")).Equal(false)
 		})
 
-		g.It("Should not match a branch with negation", func() {
-			g.Assert(matchBranch([]string{"!master"}, "master")).Equal(false)
+		g.It("Should notify on success", func() {
+			g.Assert(matchStatus([]string{"success"}, "success", "")).Equal(true)
+			g.Assert(matchStatus([]string{"success"}, "running", "")).Equal(true)
+			g.Assert(matchStatus(nil, "success", "")).Equal(true)
+			g.Assert(matchStatus(nil, "running", "")).Equal(true)
+			g.Assert(matchStatus([]string{"failure"}, "success", "")).Equal(false)
+			g.Assert(matchStatus([]string{"failure"}, "running", "")).Equal(false)
 		})
 
-		g.It("Should notify on change", func() {
-			g.Assert(matchChange("true", "success", "failure")).Equal(true)
-			g.Assert(matchChange("true", "running", "failure")).Equal(true)
+		g.It("Should notify on failure", func() {
+			g.Assert(matchStatus([]string{"failure"}, "failure", "")).Equal(true)
+			g.Assert(matchStatus([]string{"failure"}, "error", "")).Equal(true)
+			g.Assert(matchStatus([]string{"success", "failure"}, "failure", "")).Equal(true)
+			g.Assert(matchStatus([]string{"success"}, "failure", "")).Equal(false)
+			g.Assert(matchStatus([]string{"failure"}, "killed", "")).Equal(false)
 		})
 
-		g.It("Should not notify on change when no change", func() {
-			g.Assert(matchChange("true", "success", "success")).Equal(false)
+		g.It("Should notify when killed", func() {
+			g.Assert(matchStatus([]string{"killed"}, "killed", "")).Equal(true)
+			g.Assert(matchStatus([]string{"killed"}, "success", "")).Equal(false)
 		})
 
-		g.It("Should notify on success", func() {
-			g.Assert(matchSuccess("true", "success")).Equal(true)
-			g.Assert(matchSuccess("true", "running")).Equal(true)
-			g.Assert(matchSuccess("", "success")).Equal(true)
-			g.Assert(matchSuccess("", "running")).Equal(true)
-			g.Assert(matchSuccess("false", "success")).Equal(false)
-			g.Assert(matchSuccess("false", "running")).Equal(false)
+		g.It("Should match an expression", func() {
+			e, _ := expr.Parse(`(branch == "master" || event == "tag") && !fork`)
+			node := &parser.FilterNode{Expr: e}
+			state := &State{
+				Repo:      &plugin.Repo{FullName: "octocat/hello-world", Clone: "https://github.com/octocat/hello-world.git"},
+				Build:     &plugin.Build{Branch: "master", Event: plugin.EventPush},
+				Job:       &plugin.Job{Status: plugin.StateRunning},
+				System:    &plugin.System{},
+				Workspace: &plugin.Workspace{},
+			}
+			g.Assert(isMatch(node, state)).Equal(true)
+
+			state.Build = &plugin.Build{Branch: "master", Event: plugin.EventPull, Remote: "https://github.com/spork/hello-world.git"}
+			g.Assert(isMatch(node, state)).Equal(false)
+
+			state.Build = &plugin.Build{Branch: "dev", Event: plugin.EventTag, Ref: "refs/tags/v1.0.0"}
+			g.Assert(isMatch(node, state)).Equal(true)
 		})
 
-		g.It("Should not match an event", func() {
-			g.Assert(matchBranch([]string{"production"}, "deployment")).Equal(false)
+		g.It("Should match changed paths", func() {
+			changes := []string{"services/api/main.go", "README.md"}
+			g.Assert(matchChanges(include("services/api/**"), changes)).Equal(true)
+			g.Assert(matchChanges(include("services/web/**"), changes)).Equal(false)
+			g.Assert(matchChanges(exclude("**/*.md"), changes)).Equal(true)
+			g.Assert(matchChanges(exclude("**/*.md"), []string{"README.md"})).Equal(false)
+			g.Assert(matchChanges(yaml.Constraint{Include: []string{"**"}, Exclude: []string{"**/*.go", "*.md"}}, changes)).Equal(false)
 		})
 
-		g.It("Should match an event", func() {
-			g.Assert(matchBranch([]string{"deployment"}, "deployment")).Equal(true)
+		g.It("Should match when changed paths are unknown", func() {
+			g.Assert(matchChanges(include("services/api/**"), nil)).Equal(true)
+			g.Assert(matchChanges(include("services/api/**"), []string{})).Equal(false)
 		})
+
 	})
 
 }
+
+// include is a helper function that returns a constraint
+// including the patterns.
+func include(patterns ...string) yaml.Constraint {
+	return yaml.Constraint{Include: patterns}
+}
+
+// exclude is a helper function that returns a constraint
+// excluding the patterns.
+func exclude(patterns ...string) yaml.Constraint {
+	return yaml.Constraint{Exclude: patterns}
+}
+
+var constraintTests = []struct {
+	want  yaml.Constraint
+	got   string
+	match bool
+}{
+	// empty constraints match any value
+	{yaml.Constraint{}, "master", true},
+	{yaml.Constraint{}, "", true},
+
+	// exact and list matching
+	{include("master"), "master", true},
+	{include("dev"), "master", false},
+	{include("dev", "master"), "master", true},
+	{include("push", "tag"), "pull_request", false},
+	{include("octocat/hello-world"), "octocat/hello-world", true},
+	{include("octocat/hello-world"), "spork/hello-world", false},
+
+	// glob matching
+	{include("*"), "master", true},
+	{include("feature/*"), "feature/foo", true},
+	{include("feature/*"), "feature/foo/bar", false},
+	{include("feature/**"), "feature/foo/bar", true},
+	{include("octocat/*"), "octocat/hello-world", true},
+	{include("1.?"), "1.5", true},
+
+	// regular expression matching
+	{include("/^release-[0-9]+$/"), "release-42", true},
+	{include("/^release-[0-9]+$/"), "release-x", false},
+	{include("/[/"), "[", false},
+
+	// negation and exclusion
+	{exclude("dev"), "master", true},
+	{exclude("master"), "master", false},
+	{exclude("pull_request"), "push", true},
+	{exclude("pull_request"), "pull_request", false},
+	{yaml.Constraint{Include: []string{"feature/*"}, Exclude: []string{"feature/wip-*"}}, "feature/foo", true},
+	{yaml.Constraint{Include: []string{"feature/*"}, Exclude: []string{"feature/wip-*"}}, "feature/wip-foo", false},
+	{yaml.Constraint{Include: []string{"*"}, Exclude: []string{"/^dev/"}}, "develop", false},
+}
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/drone/drone-exec/artifact"
+	"github.com/drone/drone-exec/junit"
+	"github.com/drone/drone-exec/parser"
+)
+
+// reportFailures defines the number of failed tests
+// listed in the test report summary.
+const reportFailures = 5
+
+// collectReports is a helper function that parses the test
+// reports of the step, writes a summary of the test results
+// to the build output, and adds the results to the state.
+func collectReports(state *State, node *parser.DockerNode) {
+	src, ok := state.Client.(artifact.Source)
+	if !ok {
+		return
+	}
+	report := &junit.Report{}
+	err := artifact.Walk(src, state.Workspace.Path, node.JUnit, func(name string, size int64, r io.Reader) error {
+		suites, err := junit.Parse(r)
+		if err != nil {
+			fmt.Fprintf(state.Stdout, "Unable to parse test report %s. %s\n", name, err)
+			return nil
+		}
+		report.Add(suites...)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(state.Stdout, "Unable to read test reports. %s\n", err)
+	}
+	if len(report.Suites) == 0 {
+		return
+	}
+	report.WriteSummary(state.Stdout, reportFailures)
+
+	state.Lock()
+	defer state.Unlock()
+	if state.Tests == nil {
+		state.Tests = &junit.Report{}
+	}
+	state.Tests.Add(report.Suites...)
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/drone/drone-plugin-go/plugin"
+	"github.com/franela/goblin"
+)
+
+func TestUtils(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Build environment", func() {
+
+		state := &State{
+			Repo:      &plugin.Repo{FullName: "octocat/hello-world"},
+			Job:       &plugin.Job{},
+			System:    &plugin.System{Link: "http://localhost"},
+			Workspace: &plugin.Workspace{Path: "/drone/src"},
+		}
+
+		g.It("Should include the pull request number", func() {
+			state.Build = &plugin.Build{Event: plugin.EventPull, Ref: "refs/pull/42/merge"}
+			envs := toEnv(state)
+			g.Assert(contains(envs, "CI_PULL_REQUEST=42")).IsTrue()
+			g.Assert(contains(envs, "DRONE_PULL_REQUEST=42")).IsTrue()
+		})
+
+		g.It("Should include the tag name", func() {
+			state.Build = &plugin.Build{Event: plugin.EventTag, Ref: "refs/tags/v1.0"}
+			envs := toEnv(state)
+			g.Assert(contains(envs, "CI_TAG=v1.0")).IsTrue()
+			g.Assert(contains(envs, "DRONE_TAG=v1.0")).IsTrue()
+		})
+	})
+}
+
+func contains(envs []string, env string) bool {
+	for _, e := range envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/drone/drone-exec/glob"
+)
+
+// Names defines the variables available to expressions.
+// Matrix parameters and environment variables are available
+// using the matrix. and env. prefixes, for example:
+//
+//	(branch == "master" || event == "tag") && !fork
+//	matrix.GO_VERSION =~ "1.*" && env.DATABASE != "mysql"
+var Names = []string{
+	"author",
+	"branch",
+	"commit",
+	"event",
+	"fork",
+	"ref",
+	"repo",
+	"status",
+	"tag",
+}
+
+// Vars maps variable names to values. Boolean variables
+// have the value true or false.
+type Vars map[string]string
+
+// Expr is a parsed boolean expression over the build
+// metadata.
+type Expr struct {
+	raw  string
+	root node
+}
+
+// Parse parses the boolean expression. An error is returned
+// if the expression is malformed or references an unknown
+// variable.
+func Parse(raw string) (*Expr, error) {
+	tokens, err := lex(raw)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokenEOF {
+		return nil, fmt.Errorf("Unexpected %q at position %d", tok.val, tok.pos)
+	}
+	return &Expr{raw: raw, root: root}, nil
+}
+
+// Eval evaluates the expression. Variables that are not
+// defined evaluate to an empty string.
+func (e *Expr) Eval(vars Vars) bool {
+	return e.root.eval(vars)
+}
+
+// String returns the expression source.
+func (e *Expr) String() string {
+	return e.raw
+}
+
+// parser is a recursive descent parser of the token stream,
+// using the following grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | compare
+//	compare = operand [ ( "==" | "!=" ) operand | ( "=~" | "!~" ) pattern ]
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch tok := p.peek(); tok.typ {
+	case tokenNot:
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	case tokenLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.typ != tokenRParen {
+			return nil, fmt.Errorf("Expected ) at position %d", tok.pos)
+		}
+		return n, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op.typ {
+	case tokenEq, tokenNeq:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{left, right, op.typ == tokenNeq}, nil
+
+	case tokenMatch, tokenNoMatch:
+		p.next()
+		n := &matchNode{left: left, negate: op.typ == tokenNoMatch}
+		switch tok := p.next(); tok.typ {
+		case tokenString:
+			n.glob = tok.val
+		case tokenRegexp:
+			n.re, err = regexp.Compile(tok.val)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid regular expression at position %d. %s", tok.pos, err)
+			}
+		default:
+			return nil, fmt.Errorf("Expected pattern at position %d", tok.pos)
+		}
+		return n, nil
+	}
+	return &truthNode{left}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	switch tok := p.next(); tok.typ {
+	case tokenString, tokenBool:
+		return operand{value: tok.val}, nil
+	case tokenIdent:
+		if !isName(tok.val) {
+			return operand{}, fmt.Errorf("Unknown variable %s at position %d", tok.val, tok.pos)
+		}
+		return operand{name: tok.val}, nil
+	case tokenEOF:
+		return operand{}, fmt.Errorf("Unexpected end of expression")
+	default:
+		return operand{}, fmt.Errorf("Unexpected %q at position %d", tok.val, tok.pos)
+	}
+}
+
+// isName is a helper function that returns true if the
+// variable name is defined.
+func isName(name string) bool {
+	for _, prefix := range []string{"matrix.", "env."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return !strings.Contains(name[len(prefix):], ".")
+		}
+	}
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// node is a node of the expression syntax tree.
+type node interface {
+	eval(Vars) bool
+}
+
+// operand is a variable or literal value.
+type operand struct {
+	name  string
+	value string
+}
+
+func (o operand) get(vars Vars) string {
+	if len(o.name) != 0 {
+		return vars[o.name]
+	}
+	return o.value
+}
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(vars Vars) bool { return n.left.eval(vars) || n.right.eval(vars) }
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(vars Vars) bool { return n.left.eval(vars) && n.right.eval(vars) }
+
+type notNode struct{ node node }
+
+func (n *notNode) eval(vars Vars) bool { return !n.node.eval(vars) }
+
+type truthNode struct{ operand operand }
+
+func (n *truthNode) eval(vars Vars) bool { return n.operand.get(vars) == "true" }
+
+type compareNode struct {
+	left, right operand
+	negate      bool
+}
+
+func (n *compareNode) eval(vars Vars) bool {
+	return (n.left.get(vars) == n.right.get(vars)) != n.negate
+}
+
+type matchNode struct {
+	left   operand
+	glob   string
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n *matchNode) eval(vars Vars) bool {
+	value := n.left.get(vars)
+	if n.re != nil {
+		return n.re.MatchString(value) != n.negate
+	}
+	return glob.Match(n.glob, value) != n.negate
+}
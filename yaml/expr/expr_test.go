@@ -0,0 +1,85 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Expr(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Boolean expressions", func() {
+
+		vars := Vars{
+			"branch":            "master",
+			"event":             "push",
+			"repo":              "octocat/hello-world",
+			"fork":              "false",
+			"status":            "success",
+			"matrix.GO_VERSION": "1.5",
+		}
+
+		g.It("Should evaluate expressions", func() {
+			for _, test := range evalTests {
+				e, err := Parse(test.expr)
+				if err != nil {
+					g.Fail(err)
+				}
+				g.Assert(e.Eval(vars)).Equal(test.want)
+			}
+		})
+
+		g.It("Should error when the expression is invalid", func() {
+			for _, raw := range invalidTests {
+				_, err := Parse(raw)
+				g.Assert(err != nil).IsTrue()
+			}
+		})
+
+		g.It("Should return the expression source", func() {
+			e, _ := Parse(`branch == "master"`)
+			g.Assert(e.String()).Equal(`branch == "master"`)
+		})
+	})
+}
+
+var evalTests = []struct {
+	expr string
+	want bool
+}{
+	{`branch == "master"`, true},
+	{`branch != "master"`, false},
+	{`branch == 'dev'`, false},
+	{`branch == "master" || event == "tag"`, true},
+	{`branch == "dev" || event == "tag"`, false},
+	{`(branch == "master" || event == "tag") && !fork`, true},
+	{`!(branch == "master")`, false},
+	{`fork`, false},
+	{`!fork && status == "success"`, true},
+	{`tag == ""`, true},
+	{`repo =~ "octocat/*"`, true},
+	{`repo !~ "octocat/*"`, false},
+	{`branch =~ /^mas/`, true},
+	{`branch =~ /^release-[0-9]+$/`, false},
+	{`matrix.GO_VERSION =~ "1.*"`, true},
+	{`env.DATABASE == ""`, true},
+	{`branch == "dev" || event == "push" && status == "success"`, true},
+	{`true && !false`, true},
+}
+
+var invalidTests = []string{
+	``,
+	`branch ==`,
+	`branch = "master"`,
+	`branch == master`,
+	`unknown == "master"`,
+	`matrix. == "1.5"`,
+	`(branch == "master"`,
+	`branch == "master")`,
+	`branch == "master`,
+	`branch =~ /[/`,
+	`branch =~ branch`,
+	`branch == "master" &&`,
+	`branch == "master" & event == "push"`,
+}
@@ -0,0 +1,119 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenType identifies the type of a lexical token.
+type tokenType int
+
+const (
+	tokenEOF     tokenType = iota
+	tokenIdent             // branch, matrix.GO_VERSION
+	tokenString            // "master", 'master'
+	tokenRegexp            // /^release-/
+	tokenBool              // true, false
+	tokenAnd               // &&
+	tokenOr                // ||
+	tokenNot               // !
+	tokenEq                // ==
+	tokenNeq               // !=
+	tokenMatch             // =~
+	tokenNoMatch           // !~
+	tokenLParen            // (
+	tokenRParen            // )
+)
+
+// token is a lexical token of the expression.
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+// lex is a helper function that splits the expression
+// into tokens.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(input); {
+		c := input[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", pos})
+			pos++
+
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", pos})
+			pos++
+
+		case c == '"' || c == '\'' || c == '/':
+			end := strings.IndexByte(input[pos+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("Unterminated literal at position %d", pos)
+			}
+			typ := tokenString
+			if c == '/' {
+				typ = tokenRegexp
+			}
+			tokens = append(tokens, token{typ, input[pos+1 : pos+1+end], pos})
+			pos += end + 2
+
+		case strings.HasPrefix(input[pos:], "&&"):
+			tokens = append(tokens, token{tokenAnd, "&&", pos})
+			pos += 2
+
+		case strings.HasPrefix(input[pos:], "||"):
+			tokens = append(tokens, token{tokenOr, "||", pos})
+			pos += 2
+
+		case strings.HasPrefix(input[pos:], "=="):
+			tokens = append(tokens, token{tokenEq, "==", pos})
+			pos += 2
+
+		case strings.HasPrefix(input[pos:], "!="):
+			tokens = append(tokens, token{tokenNeq, "!=", pos})
+			pos += 2
+
+		case strings.HasPrefix(input[pos:], "=~"):
+			tokens = append(tokens, token{tokenMatch, "=~", pos})
+			pos += 2
+
+		case strings.HasPrefix(input[pos:], "!~"):
+			tokens = append(tokens, token{tokenNoMatch, "!~", pos})
+			pos += 2
+
+		case c == '!':
+			tokens = append(tokens, token{tokenNot, "!", pos})
+			pos++
+
+		case isIdent(rune(c)):
+			end := pos
+			for end < len(input) && (isIdent(rune(input[end])) || input[end] == '.') {
+				end++
+			}
+			word := input[pos:end]
+			typ := tokenIdent
+			if word == "true" || word == "false" {
+				typ = tokenBool
+			}
+			tokens = append(tokens, token{typ, word, pos})
+			pos = end
+
+		default:
+			return nil, fmt.Errorf("Unexpected character %q at position %d", c, pos)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+// isIdent is a helper function that returns true if the
+// character may be used in an identifier.
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,236 @@
+package inject
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// these are helper functions that bring bash-substitution
+// to the drone yaml file.
+// see http://tldp.org/LDP/abs/html/parameter-substitution.html
+//
+// The following forms are supported, where $$$$ is used to
+// escape a literal $$:
+//
+//	$$VAR            value
+//	$${VAR}          value
+//	$${VAR=default}  value, or default if empty
+//	$${VAR:-default} value, or default if empty
+//	$${VAR##prefix}  value with prefix trimmed
+//	$${VAR%%suffix}  value with suffix trimmed
+//	$${VAR/old/new}  value with old replaced by new
+//	$${VAR:pos}      value sliced up to pos
+//	$${VAR:pos:len}  value substring of len from pos
+//	$${VAR^^}        value in upper case
+//	$${VAR,,}        value in lower case
+
+// operators in order of precedence, where longer operators
+// sharing a prefix are matched first.
+var operators = []string{"##", "#", "%%", "%", ":-", ":", "=", "^^", ",,", "//", "/"}
+
+// expander performs parameter expansion in a single pass.
+type expander struct {
+	params map[string]string
+
+	// resolve is true when performing the final expansion,
+	// in which case defaults are used for parameters that
+	// are not defined and escaped $$ are unescaped.
+	resolve bool
+
+	// escape is true when injected values are escaped, so
+	// that they are not expanded by a later expansion.
+	escape bool
+}
+
+// expand expands the parameters in the string. Parameters
+// that are not defined are left unchanged.
+func (e *expander) expand(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); {
+		if !strings.HasPrefix(s[i:], "$$") {
+			buf.WriteByte(s[i])
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], "$$$$") {
+			if e.resolve {
+				buf.WriteString("$$")
+			} else {
+				buf.WriteString("$$$$")
+			}
+			i += 4
+			continue
+		}
+
+		// parses the expression following the $$ and returns
+		// the expanded value, or the unchanged text.
+		var val string
+		var ok bool
+		var end int
+		switch j := i + 2; {
+		case j < len(s) && s[j] == '{':
+			end = closing(s, j)
+			if end == -1 {
+				buf.WriteString("$$")
+				i += 2
+				continue
+			}
+			val, ok = e.expandBraces(s[j+1 : end])
+			end++
+		default:
+			end = j + identLen(s[j:])
+			if end == j {
+				buf.WriteString("$$")
+				i += 2
+				continue
+			}
+			val, ok = e.params[s[j:end]]
+			if !ok {
+				val = s[i:end]
+			}
+		}
+
+		// an injected value is escaped, so that it is not
+		// expanded when injecting parameters from other maps.
+		if ok && e.escape {
+			val = strings.Replace(val, "$$", "$$$$", -1)
+		}
+		buf.WriteString(val)
+		i = end
+	}
+	return buf.String()
+}
+
+// expandBraces expands the body of the $${...} expression
+// and returns the value. If the parameter is not defined or
+// the expression is invalid, the unchanged text is returned
+// with a false value.
+func (e *expander) expandBraces(body string) (string, bool) {
+	raw := "$${" + body + "}"
+
+	n := identLen(body)
+	if n == 0 {
+		return raw, false
+	}
+	name, rest := body[:n], body[n:]
+	var op, arg string
+	for _, o := range operators {
+		if strings.HasPrefix(rest, o) {
+			op, arg = o, rest[len(o):]
+			break
+		}
+	}
+	if len(op) == 0 && len(rest) != 0 {
+		return raw, false
+	}
+
+	val, ok := e.params[name]
+	if !ok {
+		switch {
+		case op != "=" && op != ":-":
+			return raw, false
+		case e.resolve:
+			return e.expand(arg), true
+		default:
+			// the default value is expanded, since it may
+			// reference parameters that are defined.
+			return "$${" + name + op + e.expand(arg) + "}", false
+		}
+	}
+
+	switch op {
+	case "":
+		return val, true
+	case "##", "#":
+		return strings.TrimPrefix(val, arg), true
+	case "%%", "%":
+		return strings.TrimSuffix(val, arg), true
+	case "=", ":-":
+		if len(val) == 0 {
+			return e.expand(arg), true
+		}
+		return val, true
+	case "^^", ",,":
+		if len(arg) != 0 {
+			return raw, false
+		}
+		if op == "^^" {
+			return strings.ToUpper(val), true
+		}
+		return strings.ToLower(val), true
+	case "/", "//":
+		parts := strings.SplitN(arg, "/", 2)
+		if len(parts[0]) == 0 {
+			return val, true
+		}
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		return strings.Replace(val, parts[0], parts[1], -1), true
+	case ":":
+		if sub, ok := substr(val, arg); ok {
+			return sub, true
+		}
+	}
+	return raw, false
+}
+
+// substr is a helper function that returns the substring
+// of the value for the pos or pos:len argument. The
+// positions are limited to the bounds of the value.
+func substr(val, arg string) (string, bool) {
+	parts := strings.SplitN(arg, ":", 2)
+	pos, err := strconv.Atoi(parts[0])
+	if err != nil || pos < 0 {
+		return "", false
+	}
+	if pos > len(val) {
+		pos = len(val)
+	}
+	if len(parts) == 1 {
+		return val[:pos], true
+	}
+	length, err := strconv.Atoi(parts[1])
+	if err != nil || length < 0 {
+		return "", false
+	}
+	if pos+length > len(val) {
+		length = len(val) - pos
+	}
+	return val[pos : pos+length], true
+}
+
+// closing is a helper function that returns the index of
+// the brace closing the brace at the index, or -1 if the
+// brace is not closed.
+func closing(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// identLen is a helper function that returns the length of
+// the parameter name at the start of the string.
+func identLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i != 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,105 @@
+package inject
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Expand(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Parameter expansion", func() {
+
+		g.It("Should expand parameters", func() {
+			for _, test := range expandTests {
+				e := &expander{params: test.params, escape: true}
+				g.Assert(e.expand(test.before)).Equal(test.after)
+			}
+		})
+
+		g.It("Should resolve defaults and escapes", func() {
+			for _, test := range resolveTests {
+				e := &expander{params: test.params, escape: true}
+				r := &expander{resolve: true}
+				g.Assert(r.expand(e.expand(test.before))).Equal(test.after)
+			}
+		})
+
+		g.It("Should not expand injected parameters", func() {
+			s := "echo $$FOO $$BAR"
+			s = (&expander{params: map[string]string{"FOO": "$$BAR"}, escape: true}).expand(s)
+			s = (&expander{params: map[string]string{"BAR": "BAZ"}, escape: true}).expand(s)
+			g.Assert((&expander{resolve: true}).expand(s)).Equal("echo $$BAR BAZ")
+		})
+	})
+}
+
+var expandTests = []struct {
+	before string
+	params map[string]string
+	after  string
+}{
+	// simple parameters
+	{"echo $GREETING WORLD", map[string]string{"GREETING": "HELLO"}, "echo $GREETING WORLD"},
+	{"echo $$GREETING WORLD", map[string]string{"GREETING": "HELLO"}, "echo HELLO WORLD"},
+	{"echo $${GREETING} WORLD", map[string]string{"GREETING": "HELLO"}, "echo HELLO WORLD"},
+	{"echo $$GREETING$$NAME", map[string]string{"GREETING": "HELLO", "NAME": "WORLD"}, "echo HELLOWORLD"},
+	{"echo $${GREETING##x} $${NAME,,}", map[string]string{"GREETING": "xHELLO", "NAME": "WORLD"}, "echo HELLO world"},
+
+	// parameters sharing a prefix
+	{"echo $$BRANCH_NAME", map[string]string{"BRANCH": "master"}, "echo $$BRANCH_NAME"},
+	{"echo $$BRANCH_NAME $$BRANCH", map[string]string{"BRANCH": "master", "BRANCH_NAME": "dev"}, "echo dev master"},
+	{"echo $${BRANCH}_NAME", map[string]string{"BRANCH": "master"}, "echo master_NAME"},
+
+	// trim prefix and suffix
+	{"echo $${GREETING##asdf} WORLD", map[string]string{"GREETING": "asdfHELLO"}, "echo HELLO WORLD"},
+	{"echo $${GREETING#asdf} WORLD", map[string]string{"GREETING": "asdfHELLO"}, "echo HELLO WORLD"},
+	{"echo $${GREETING%%asdf} WORLD", map[string]string{"GREETING": "HELLOasdf"}, "echo HELLO WORLD"},
+	{"echo $${GREETING%asdf} WORLD", map[string]string{"GREETING": "HELLOasdf"}, "echo HELLO WORLD"},
+
+	// defaults
+	{"echo $${GREETING=HOLA} WORLD", map[string]string{"GREETING": "HELLO"}, "echo HELLO WORLD"},
+	{"echo $${GREETING=HOLA} WORLD", map[string]string{"GREETING": ""}, "echo HOLA WORLD"},
+	{"echo $${GREETING:-HOLA} WORLD", map[string]string{"GREETING": ""}, "echo HOLA WORLD"},
+	{"echo $${GREETING:-$$NAME}", map[string]string{"GREETING": "", "NAME": "HOLA"}, "echo HOLA"},
+	{"echo $${GREETING:-$$NAME}", map[string]string{"NAME": "HOLA"}, "echo $${GREETING:-HOLA}"},
+
+	// replacement
+	{"echo $${GREETING/HE/A} MONDE", map[string]string{"GREETING": "HELLO"}, "echo ALLO MONDE"},
+	{"echo $${GREETING//L/} MONDE", map[string]string{"GREETING": "HELLO"}, "echo HEO MONDE"},
+
+	// case modification
+	{"echo $${GREETING^^}", map[string]string{"GREETING": "hello"}, "echo HELLO"},
+	{"echo $${GREETING,,}", map[string]string{"GREETING": "HELLO"}, "echo hello"},
+
+	// substrings
+	{"echo $${FOO:4} IS COOL", map[string]string{"FOO": "THIS IS A REALLY LONG STRING"}, "echo THIS IS COOL"},
+	{"echo $${FOO:8:5} IS COOL", map[string]string{"FOO": "THIS IS DRONE CI"}, "echo DRONE IS COOL"},
+	{"echo $${FOO:8}", map[string]string{"FOO": "abc"}, "echo abc"},
+	{"echo $${FOO:1:8}", map[string]string{"FOO": "abc"}, "echo bc"},
+	{"echo $${FOO:8:2}", map[string]string{"FOO": "abc"}, "echo "},
+	{"echo $${FOO:x}", map[string]string{"FOO": "abc"}, "echo $${FOO:x}"},
+
+	// unknown parameters and invalid expressions
+	{"echo $$BAR $${BAR} $${BAR##x}", map[string]string{"FOO": "abc"}, "echo $$BAR $${BAR} $${BAR##x}"},
+	{"echo $${FOO", map[string]string{"FOO": "abc"}, "echo $${FOO"},
+	{"echo $${FOO?}", map[string]string{"FOO": "abc"}, "echo $${FOO?}"},
+	{"echo $$ $$1", map[string]string{"FOO": "abc"}, "echo $$ $$1"},
+
+	// escaped dollar signs
+	{"echo $$$$FOO", map[string]string{"FOO": "abc"}, "echo $$$$FOO"},
+	{"echo $$FOO", map[string]string{"FOO": "a$$b"}, "echo a$$$$b"},
+}
+
+var resolveTests = []struct {
+	before string
+	params map[string]string
+	after  string
+}{
+	{"echo $${GREETING=HOLA} WORLD", nil, "echo HOLA WORLD"},
+	{"echo $${GREETING:-$$NAME}", map[string]string{"NAME": "HOLA"}, "echo HOLA"},
+	{"echo $$GREETING WORLD", nil, "echo $$GREETING WORLD"},
+	{"echo $$$$FOO", map[string]string{"FOO": "abc"}, "echo $$FOO"},
+	{"echo $$FOO", map[string]string{"FOO": "a$$b"}, "echo a$$b"},
+}
@@ -1,38 +1,47 @@
 package inject
 
-import (
-	"sort"
+import "gopkg.in/yaml.v2"
 
-	"gopkg.in/yaml.v2"
-)
-
-// Inject injects a map of parameters into a raw string and returns
-// the resulting string.
+// Inject injects a map of parameters into the yaml and returns
+// the resulting yaml.
+//
+// Parameters are represented in the yaml using $$ notation, similar
+// to how environment variables are defined in Makefiles. Parameters
+// not in the map are left unchanged, so that parameters can be
+// injected from multiple maps before calling Resolve.
 //
-// Parameters are represented in the string using $$ notation, similar
-// to how environment variables are defined in Makefiles.
-func Inject(raw string, params map[string]string) string {
+// Parameters are injected into the string values of the parsed yaml,
+// and not the raw text, so that an injected value is always a string
+// in the position where the parameter appeared, regardless of its
+// content, and cannot alter the structure of the yaml.
+func Inject(raw string, params map[string]string) (string, error) {
 	if params == nil || len(params) == 0 {
-		return raw
-	}
-	keys := []string{}
-	for k := range params {
-		keys = append(keys, k)
+		return raw, nil
 	}
-	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
-	injected := raw
-	for _, k := range keys {
-		v := params[k]
+	return inject(raw, &expander{params: params, escape: true}, false)
+}
 
-		for _, substitute := range substitutors {
-			injected = substitute(injected, k, v)
-		}
+// Resolve completes the injection after all parameters are
+// injected. The default value is used for parameters that were
+// not injected, and the escaped $$$$ is replaced with $$.
+func Resolve(raw string) (string, error) {
+	return inject(raw, &expander{resolve: true}, false)
+}
+
+// InjectValue injects a map of parameters into the string values
+// of the parsed yaml value, in the same manner as Inject, and
+// returns the resulting value. This is used to inject parameters
+// into individual steps of the yaml before calling Resolve.
+func InjectValue(v interface{}, params map[string]string) interface{} {
+	if params == nil || len(params) == 0 {
+		return v
 	}
-	return injected
+	e := &expander{params: params, escape: true}
+	return e.walk(v)
 }
 
 // InjectSafe attempts to safely inject parameters without leaking
-// parameters in the Build or Compose section of the yaml file.
+// parameters in the Build section of the yaml file.
 //
 // The intended use case for this function are public pull requests.
 // We want to avoid a malicious pull request that allows someone
@@ -41,29 +50,49 @@ func InjectSafe(raw string, params map[string]string) (string, error) {
 	if params == nil || len(params) == 0 {
 		return raw, nil
 	}
-	before, err := parse(raw)
+	return inject(raw, &expander{params: params, escape: true}, true)
+}
+
+// inject parses the yaml file and expands the parameters in the
+// string values, preserving the order of the mapping keys. If safe
+// is true the build section is not expanded.
+func inject(raw string, e *expander, safe bool) (string, error) {
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal([]byte(raw), &doc)
 	if err != nil {
 		return raw, err
 	}
-	after, err := parse(Inject(raw, params))
+	for i, item := range doc {
+		if safe && item.Key == "build" {
+			continue
+		}
+		doc[i].Value = e.walk(item.Value)
+	}
+	out, err := yaml.Marshal(doc)
 	if err != nil {
 		return raw, err
 	}
-	after.Build = before.Build
-	result, err := yaml.Marshal(after)
-	return string(result), err
-}
-
-// parse unmarshals the yaml file into an intermediate representation
-// that isolates the build section. This allows us to modify the rest
-// of the Yaml file while preserving the build section.
-func parse(raw string) (*config, error) {
-	conf := &config{}
-	err := yaml.Unmarshal([]byte(raw), &conf)
-	return conf, err
+	return string(out), nil
 }
 
-type config struct {
-	Build map[string]interface{} `yaml:"build"`
-	Vargs map[string]interface{} `yaml:"vargs,inline"`
+// walk expands the parameters in the string values of the parsed
+// yaml value. Mapping keys are not expanded.
+func (e *expander) walk(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return e.expand(v)
+	case yaml.MapSlice:
+		for i := range v {
+			v[i].Value = e.walk(v[i].Value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = e.walk(v[i])
+		}
+	case map[interface{}]interface{}:
+		for key, val := range v {
+			v[key] = e.walk(val)
+		}
+	}
+	return v
 }
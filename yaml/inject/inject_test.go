@@ -13,44 +13,82 @@ func Test_Inject(t *testing.T) {
 	g.Describe("Inject params", func() {
 
 		g.It("Should replace vars with $$", func() {
-			s := "echo $$FOO $BAR"
-			m := map[string]string{}
-			m["FOO"] = "BAZ"
-			g.Assert("echo BAZ $BAR").Equal(Inject(s, m))
+			s, err := Inject("command: echo $$FOO $BAR", map[string]string{"FOO": "BAZ"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s).Equal("command: echo BAZ $BAR\n")
 		})
 
 		g.It("Should not replace vars with single $", func() {
-			s := "echo $FOO $BAR"
-			m := map[string]string{}
-			m["FOO"] = "BAZ"
-			g.Assert(s).Equal(Inject(s, m))
+			s, err := Inject("command: echo $FOO $BAR", map[string]string{"FOO": "BAZ"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s).Equal("command: echo $FOO $BAR\n")
 		})
 
 		g.It("Should not replace vars in nil map", func() {
-			s := "echo $$FOO $BAR"
-			g.Assert(s).Equal(Inject(s, nil))
+			s := "command: echo $$FOO $BAR"
+			after, err := Inject(s, nil)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(after).Equal(s)
 		})
 
-		g.It("Should escape quoted variables", func() {
-			s := `echo "$$FOO"`
-			m := map[string]string{}
-			m["FOO"] = "hello\nworld"
-			g.Assert(`echo "hello\nworld"`).Equal(Inject(s, m))
+		g.It("Should not escape quoted variables", func() {
+			s, err := Inject(`command: echo "$$FOO"`, map[string]string{"FOO": "hello\nworld"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(value(s, "command")).Equal("echo \"hello\nworld\"")
+		})
+
+		g.It("Should preserve tabs and newlines", func() {
+			s, err := Inject(`command: $$FOO`, map[string]string{"FOO": "hello\tworld\n"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(value(s, "command")).Equal("hello\tworld\n")
 		})
 
 		g.It("Should replace variable prefix", func() {
-			s := `tag: $${TAG=$${SHA:8}}`
 			m := map[string]string{}
 			m["TAG"] = ""
 			m["SHA"] = "f36cbf54ee1a1eeab264c8e388f386218ab1701b"
-			g.Assert("tag: f36cbf54").Equal(Inject(s, m))
+			s, err := Inject(`tag: $${TAG=$${SHA:8}}`, m)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s).Equal("tag: f36cbf54\n")
 		})
 
 		g.It("Should handle nested substitution operations", func() {
-			s := `echo "$${TAG##v}"`
-			m := map[string]string{}
-			m["TAG"] = "v1.0.0"
-			g.Assert(`echo "1.0.0"`).Equal(Inject(s, m))
+			s, err := Inject(`command: echo "$${TAG##v}"`, map[string]string{"TAG": "v1.0.0"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(value(s, "command")).Equal(`echo "1.0.0"`)
+		})
+
+		g.It("Should not alter the yaml structure", func() {
+			m := map[string]string{
+				"BRANCH": "master\nprivileged: true",
+				"TOKEN":  "foo: bar # baz",
+			}
+			s, err := Inject("branch: $$BRANCH\ntoken: $$TOKEN\n", m)
+			g.Assert(err == nil).IsTrue()
+
+			after := map[string]interface{}{}
+			err = yaml.Unmarshal([]byte(s), &after)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(after)).Equal(2)
+			g.Assert(after["branch"]).Equal("master\nprivileged: true")
+			g.Assert(after["token"]).Equal("foo: bar # baz")
+		})
+
+		g.It("Should preserve the order of the yaml", func() {
+			s, err := Inject("notify:\n  slack: {}\n  email: {}\nbuild: {}\n", map[string]string{"FOO": "BAR"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s).Equal("notify:\n  slack: {}\n  email: {}\nbuild: {}\n")
+		})
+
+		g.It("Should error when the yaml is invalid", func() {
+			_, err := Inject("build: [", map[string]string{"FOO": "BAR"})
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should resolve default values", func() {
+			s, err := Resolve("tag: $${TAG=latest}\ncommand: echo $$$$HOME\n")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(s).Equal("tag: latest\ncommand: echo $$HOME\n")
 		})
 
 		g.It("Should safely inject params", func() {
@@ -100,6 +138,14 @@ func Test_Inject(t *testing.T) {
 	})
 }
 
+// value is a helper function that returns the string value
+// of the key in the yaml document.
+func value(raw, key string) string {
+	doc := map[string]string{}
+	yaml.Unmarshal([]byte(raw), &doc)
+	return doc[key]
+}
+
 var before = `
 build:
   image: foo
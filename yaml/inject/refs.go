@@ -0,0 +1,135 @@
+package inject
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Ref is a reference to a parameter in the yaml file.
+type Ref struct {
+	Name string
+	Line int
+
+	// Default is true if the reference provides a default
+	// value that is used when the parameter is not defined.
+	Default bool
+}
+
+// Refs returns the parameter references in the raw string,
+// in order of appearance. References in a default value are
+// included, and escaped $$$$ are ignored.
+func Refs(raw string) []Ref {
+	var refs []Ref
+	scan(raw, 0, func(name string, pos int, def bool) {
+		line := strings.Count(raw[:pos], "\n") + 1
+		refs = append(refs, Ref{Name: name, Line: line, Default: def})
+	})
+	return refs
+}
+
+// ValueRefs returns the parameter references in the string
+// values of the parsed yaml value. The line numbers are relative
+// to the string value of each reference.
+func ValueRefs(v interface{}) []Ref {
+	var refs []Ref
+	switch v := v.(type) {
+	case string:
+		refs = Refs(v)
+	case yaml.MapSlice:
+		for _, item := range v {
+			refs = append(refs, ValueRefs(item.Value)...)
+		}
+	case []interface{}:
+		for _, val := range v {
+			refs = append(refs, ValueRefs(val)...)
+		}
+	case map[interface{}]interface{}:
+		for _, val := range v {
+			refs = append(refs, ValueRefs(val)...)
+		}
+	}
+	return refs
+}
+
+// Unresolved returns the parameter references in the raw yaml
+// that are not resolved in the injected yaml, because the parameter
+// is not defined and no default value is provided. The references
+// are returned with the line numbers of the raw yaml, since the
+// injected yaml is re-encoded.
+func Unresolved(raw, injected string) []Ref {
+	var names = map[string]bool{}
+	for _, ref := range Refs(injected) {
+		if !ref.Default {
+			names[ref.Name] = true
+		}
+	}
+	var refs []Ref
+	for _, ref := range Refs(raw) {
+		if !ref.Default && names[ref.Name] {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// Substituted returns the parameter references in the raw
+// string that are substituted when injecting the map of
+// parameters.
+func Substituted(raw string, params map[string]string) []Ref {
+	var refs []Ref
+	for _, ref := range Refs(raw) {
+		if _, ok := params[ref.Name]; ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// scan is a helper function that calls the function for each
+// parameter reference in the string, with the position of the
+// reference offset by base.
+func scan(s string, base int, fn func(name string, pos int, def bool)) {
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$$$$"):
+			i += 4
+			continue
+		case !strings.HasPrefix(s[i:], "$$"):
+			i++
+			continue
+		}
+
+		j := i + 2
+		if j >= len(s) || s[j] != '{' {
+			n := identLen(s[j:])
+			if n != 0 {
+				fn(s[j:j+n], base+i, false)
+			}
+			i = j + n
+			continue
+		}
+		end := closing(s, j)
+		if end == -1 {
+			i = j
+			continue
+		}
+		body := s[j+1 : end]
+		n := identLen(body)
+		if n == 0 {
+			i = end + 1
+			continue
+		}
+		switch rest := body[n:]; {
+		case strings.HasPrefix(rest, ":-"):
+			fn(body[:n], base+i, true)
+			scan(rest[2:], base+j+1+n+2, fn)
+		case strings.HasPrefix(rest, "="):
+			fn(body[:n], base+i, true)
+			scan(rest[1:], base+j+1+n+1, fn)
+		default:
+			fn(body[:n], base+i, false)
+		}
+		i = end + 1
+	}
+}
@@ -0,0 +1,51 @@
+package inject
+
+import (
+	"testing"
+
+	"github.com/franela/goblin"
+)
+
+func Test_Refs(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Parameter references", func() {
+
+		raw := "build:\n  image: golang\n  commands:\n    - echo $$GREETING $${NAME##x}\n    - echo $$$$ESCAPED $${TAG=$${SHA:8}} $${BRANCH:-master}\n"
+
+		g.It("Should list references with line numbers", func() {
+			refs := Refs(raw)
+			g.Assert(refs).Equal([]Ref{
+				{Name: "GREETING", Line: 4},
+				{Name: "NAME", Line: 4},
+				{Name: "TAG", Line: 5, Default: true},
+				{Name: "SHA", Line: 5},
+				{Name: "BRANCH", Line: 5, Default: true},
+			})
+		})
+
+		g.It("Should list unresolved references", func() {
+			s, err := Inject(raw, map[string]string{"GREETING": "hello"})
+			g.Assert(err == nil).IsTrue()
+			refs := Unresolved(raw, s)
+			g.Assert(refs).Equal([]Ref{
+				{Name: "NAME", Line: 4},
+				{Name: "SHA", Line: 5},
+			})
+		})
+
+		g.It("Should list substituted references", func() {
+			refs := Substituted(raw, map[string]string{"GREETING": "hello", "BRANCH": "dev"})
+			g.Assert(refs).Equal([]Ref{
+				{Name: "GREETING", Line: 4},
+				{Name: "BRANCH", Line: 5, Default: true},
+			})
+		})
+
+		g.It("Should not list references in a resolved yaml", func() {
+			s, err := Inject(raw, map[string]string{"GREETING": "hello", "NAME": "xdrone", "TAG": "v1"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(Unresolved(raw, s))).Equal(0)
+		})
+	})
+}
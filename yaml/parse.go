@@ -31,3 +31,20 @@ func ParseDebug(in []byte) bool {
 func ParseDebugString(in string) bool {
 	return ParseDebug([]byte(in))
 }
+
+// ParseStrictVars parses a Yaml configuration file in
+// order to extract the `strict_vars` field value.
+func ParseStrictVars(in []byte) bool {
+	var c = struct {
+		StrictVars bool `yaml:"strict_vars"`
+	}{}
+	yaml.Unmarshal(in, &c)
+	return c.StrictVars
+}
+
+// ParseStrictVarsString parses a Yaml configuration file
+// in string format and attempts to extract the `strict_vars`
+// field value.
+func ParseStrictVarsString(in string) bool {
+	return ParseStrictVars([]byte(in))
+}
@@ -22,7 +22,17 @@ func TestParse(t *testing.T) {
 		})
 
 		g.It("Should parse image force-pull", func() {
-			g.Assert(conf.Clone.Pull).Equal(true)
+			g.Assert(conf.Clone.Pull).Equal(PullAlways)
+		})
+
+		g.It("Should parse image pull policy", func() {
+			g.Assert(conf.Build.Pull).Equal(PullNever)
+			g.Assert(conf.Compose.Slice()[0].Pull).Equal(PullIfNotPresent)
+		})
+
+		g.It("Should error when pull policy is invalid", func() {
+			_, err := ParseString("build: { image: golang, pull: sometimes }")
+			g.Assert(err != nil).IsTrue()
 		})
 
 		g.It("Should parse variable arguments", func() {
@@ -37,6 +47,18 @@ func TestParse(t *testing.T) {
 			g.Assert(conf.Build.Commands).Equal([]string{"go build", "go test"})
 		})
 
+		g.It("Should parse build reports", func() {
+			g.Assert(conf.Build.Reports.JUnit.Slice()).Equal([]string{"build/test-results/*.xml"})
+		})
+
+		g.It("Should parse build filters", func() {
+			g.Assert(conf.Build.Filter.Event.Exclude).Equal([]string{"deployment"})
+		})
+
+		g.It("Should parse compose filters", func() {
+			g.Assert(conf.Compose.Slice()[1].Filter.Matrix["SUITE"].Include).Equal([]string{"integration"})
+		})
+
 		g.It("Should parse volume configuration", func() {
 			g.Assert(conf.Build.Volumes).Equal([]string{"/tmp/volumes"})
 		})
@@ -91,9 +113,65 @@ func TestParse(t *testing.T) {
 
 		g.It("Should parse plugin filters", func() {
 			s := conf.Deploy.Slice()
-			g.Assert(s[0].Filter.Branch.Slice()).Equal([]string{"master"})
-			g.Assert(s[1].Filter.Repo).Equal("octocat/helloworld")
-			g.Assert(s[1].Filter.Matrix).Equal(map[string]string{"go_version": "1.5"})
+			g.Assert(s[0].Filter.Branch.Include).Equal([]string{"master"})
+			g.Assert(s[1].Filter.Repo.Include).Equal([]string{"octocat/helloworld"})
+			g.Assert(s[1].Filter.Matrix["go_version"].Include).Equal([]string{"1.5"})
+		})
+
+		g.It("Should parse plugin filter negation", func() {
+			s := conf.Deploy.Slice()
+			g.Assert(s[1].Filter.Branch.Include).Equal([]string{"feature/*"})
+			g.Assert(s[1].Filter.Branch.Exclude).Equal([]string{"feature/wip-*"})
+			g.Assert(s[1].Filter.Event.Exclude).Equal([]string{"pull_request"})
+			g.Assert(s[1].Filter.Tag.Include).Equal([]string{"v*"})
+		})
+
+		g.It("Should parse plugin path filters", func() {
+			s := conf.Deploy.Slice()
+			g.Assert(s[0].Filter.Path.Include).Equal([]string{"services/api/**"})
+			g.Assert(s[1].Filter.Path.Include).Equal([]string{"services/**"})
+			g.Assert(s[1].Filter.Path.Exclude).Equal([]string{"**/*.md"})
+		})
+
+		g.It("Should parse plugin filter expressions", func() {
+			s := conf.Deploy.Slice()
+			g.Assert(s[0].Filter.Expr).Equal(`event == "push" && !fork`)
+		})
+
+		g.It("Should parse the native cache", func() {
+			conf, err := ParseString(nativeCache)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(conf.Cache.Key).Equal(`deps-{{ checksum "go.sum" }}`)
+			g.Assert(conf.Cache.Fallback).Equal([]string{"deps-"})
+			g.Assert(conf.Cache.Paths).Equal([]string{"vendor"})
+			g.Assert(len(conf.Cache.Vargs)).Equal(0)
+		})
+
+		g.It("Should error when the cache key is missing", func() {
+			_, err := ParseString("cache: { key: '', paths: [ vendor ] }")
+			g.Assert(err).Equal(ErrCacheKey)
+			_, err = ParseString("cache: { paths: [ vendor ] }")
+			g.Assert(err).Equal(ErrCacheKey)
+		})
+
+		g.It("Should parse the cache plugin", func() {
+			conf, err := ParseString(pluginCache)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(conf.Cache.Paths)).Equal(0)
+			g.Assert(conf.Cache.Vargs["mount"]).Equal([]interface{}{"node_modules"})
+		})
+
+		g.It("Should parse the artifacts", func() {
+			conf, err := ParseString(artifacts)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(conf.Artifacts.Paths).Equal([]string{"dist/*.tar.gz", "coverage"})
+			g.Assert(conf.Artifacts.Filter.Failure).Equal("true")
+		})
+
+		g.It("Should parse the artifacts path list", func() {
+			conf, err := ParseString(artifactsList)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(conf.Artifacts.Paths).Equal([]string{"dist/*.tar.gz"})
 		})
 
 		g.It("should error when Yaml is malformed", func() {
@@ -114,11 +192,16 @@ clone:
 
 build:
   image: golang
+  pull: never
   environment:
     - GO15VENDOREXPERIMENT=1
   commands:
     - go build
     - go test
+  reports:
+    junit: build/test-results/*.xml
+  when:
+    event: "!deployment"
   volumes:
     - /tmp/volumes
   net: bridge
@@ -127,6 +210,7 @@ build:
 compose:
   redis:
     image: library/redis
+    pull: if-not-present
     command: redis-server /usr/local/etc/redis/redis.conf --appendonly yes
 
   mongo:
@@ -134,19 +218,53 @@ compose:
     command:
       - --storageEngine
       - wiredTiger
+    when:
+      matrix:
+        SUITE: integration
 
 deploy:
   heroku:
     app: foo.com
     when:
       branch: master
+      path: services/api/**
+      expr: event == "push" && !fork
   heroku:
     app: dev.foo.com
     when:
       repo: octocat/helloworld
-      branch: somebranch
+      branch: [ "feature/*", "!feature/wip-*" ]
+      event:
+        exclude: pull_request
+      tag: v*
       matrix:
         go_version: 1.5
+      path:
+        include: [ services/** ]
+        exclude: [ "**/*.md" ]
+`
+
+var nativeCache = `
+cache:
+  key: deps-{{ checksum "go.sum" }}
+  fallback_keys: [ deps- ]
+  paths: [ vendor ]
+`
+
+var pluginCache = `
+cache:
+  mount: [ node_modules ]
+`
+
+var artifacts = `
+artifacts:
+  paths: [ dist/*.tar.gz, coverage ]
+  when:
+    failure: true
+`
+
+var artifactsList = `
+artifacts: [ dist/*.tar.gz ]
 `
 
 var malformed = `build: { image: golang:1.4.2, commands: [ go build, go test ] }`
@@ -1,30 +1,66 @@
 package secure
 
 import (
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/square/go-jose"
 	"gopkg.in/yaml.v2"
 )
 
+var (
+	ErrKeyInvalid = errors.New("Invalid private key. Expected a PEM encoded key")
+	ErrKeyType    = errors.New("Invalid private key. Expected an RSA or EC key")
+	ErrDecrypt    = errors.New("Unable to decrypt the secrets with the private keys")
+	ErrPublicKey  = errors.New("Invalid public key. Expected a PEM encoded RSA or EC key")
+	ErrRepo       = errors.New("Secrets are encrypted for a different repository")
+	ErrUnbound    = errors.New("Secrets are not encrypted for a repository")
+	ErrExpired    = errors.New("Secrets are expired")
+	ErrNotBefore  = errors.New("Secrets are not valid yet")
+)
+
+// now returns the current time, and may be replaced
+// when testing the expiry claims.
+var now = time.Now
+
 type Secure struct {
 	Checksum    string        `yaml:"checksum"`
+	Repo        string        `yaml:"repo"` // repository full name
+	Expires     int64         `yaml:"exp"`  // unix time, optional
+	NotBefore   int64         `yaml:"nbf"`  // unix time, optional
 	Environment MapEqualSlice `yaml:"environment"`
+	Registries  []Auth        `yaml:"registries"`
+}
+
+// Auth defines the credentials used to authenticate
+// image pulls from a private registry.
+type Auth struct {
+	Registry string `yaml:"registry"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Email    string `yaml:"email"`
 }
 
 // Parse parses and returns the secure section of the
 // yaml file as plaintext parameters.
-func Parse(in, privKey string) (*Secure, error) {
-	// unarmshal the private key from PEM
-	rsaPrivKey, err := decodePrivateKey(privKey)
-	if err != nil {
-		return nil, err
-	}
-
+//
+// The private key may contain multiple PEM encoded keys,
+// the current key followed by the previous keys, which are
+// tried in order so that keys can be rotated without
+// re-encrypting the secure section.
+//
+// The secrets are rejected if they are encrypted for a
+// repository other than the named repository, or if they
+// are expired or not yet valid.
+func Parse(in, privKey, repo string) (*Secure, error) {
 	// decrypt the Yaml file
-	plain, err := decrypt(in, rsaPrivKey)
+	plain, err := Decrypt(in, privKey)
 	if err != nil {
 		return nil, err
 	}
@@ -32,24 +68,190 @@ func Parse(in, privKey string) (*Secure, error) {
 	// unmarshal the yaml
 	out := &Secure{}
 	err = yaml.Unmarshal(plain, out)
-	return out, err
+	if err != nil {
+		return nil, err
+	}
+	err = out.Validate(repo)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Validate checks the repository, expiry and not before
+// claims of the secure section. Claims that are not set
+// are not checked, so that secrets encrypted without the
+// claims can still be used. The repository name is not
+// case sensitive.
+func (s *Secure) Validate(repo string) error {
+	if len(s.Repo) != 0 && !strings.EqualFold(s.Repo, repo) {
+		return ErrRepo
+	}
+	unix := now().Unix()
+	if s.Expires != 0 && unix >= s.Expires {
+		return ErrExpired
+	}
+	if s.NotBefore != 0 && unix < s.NotBefore {
+		return ErrNotBefore
+	}
+	return nil
+}
+
+// Decrypt decrypts the secure section of the yaml file with
+// the PEM encoded private keys and returns the plaintext.
+func Decrypt(in, privKey string) ([]byte, error) {
+	// unarmshal the private keys from PEM
+	keys, err := decodePrivateKeys(privKey)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(in, keys...)
+}
+
+// Encrypt encrypts the plaintext secure section of the yaml
+// file with the PEM encoded public key.
+func Encrypt(plain, pubKey string) (string, error) {
+	keys, err := decodePublicKeys(pubKey)
+	if err != nil {
+		return "", err
+	}
+	return encrypt(plain, keys[0])
 }
 
-// decrypt decrypts a JOSE string and returns the
-// plaintext value.
-func decrypt(secret string, privKey *rsa.PrivateKey) ([]byte, error) {
+// SetChecksum sets the checksum of the yaml file in the
+// plaintext secure section and returns the plaintext.
+func SetChecksum(plain []byte, checksum string) ([]byte, error) {
+	return SetClaim(plain, "checksum", checksum)
+}
+
+// SetClaim sets the value of the named key, such as the repo,
+// exp or nbf claim, in the plaintext secure section and
+// returns the plaintext. Keys that are not yet set are added
+// to the top of the secure section.
+func SetClaim(plain []byte, key string, value interface{}) ([]byte, error) {
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal(plain, &doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range doc {
+		if item.Key == key {
+			doc[i].Value = value
+			return yaml.Marshal(doc)
+		}
+	}
+	doc = append(yaml.MapSlice{{Key: key, Value: value}}, doc...)
+	return yaml.Marshal(doc)
+}
+
+// decrypt decrypts a JOSE string with the first private
+// key that succeeds and returns the plaintext value.
+func decrypt(secret string, privKeys ...interface{}) ([]byte, error) {
 	object, err := jose.ParseEncrypted(secret)
 	if err != nil {
 		return nil, err
 	}
-	return object.Decrypt(privKey)
+	for _, privKey := range privKeys {
+		plain, err := object.Decrypt(privKey)
+		if err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrDecrypt
+}
+
+// decodePrivateKeys is a helper function that unmarshals the
+// PEM encoded private keys, in order of appearance.
+func decodePrivateKeys(privateKeys string) ([]interface{}, error) {
+	var keys []interface{}
+	rest := []byte(privateKeys)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		// the curve parameters precede the private key
+		// when generated with openssl ecparam.
+		if block.Type == "EC PARAMETERS" {
+			continue
+		}
+		key, err := decodePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, ErrKeyInvalid
+	}
+	return keys, nil
 }
 
 // decodePrivateKey is a helper function that unmarshals a PEM
-// bytes to an RSA Private Key
-func decodePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
-	derBlock, _ := pem.Decode([]byte(privateKey))
-	return x509.ParsePKCS1PrivateKey(derBlock.Bytes)
+// block to an RSA or EC private key, in PKCS#1, SEC 1 or PKCS#8
+// format.
+func decodePrivateKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+			return key, nil
+		}
+	}
+	return nil, ErrKeyType
+}
+
+// decodePublicKeys is a helper function that unmarshals the
+// PEM encoded public keys, in order of appearance.
+func decodePublicKeys(publicKeys string) ([]interface{}, error) {
+	var keys []interface{}
+	rest := []byte(publicKeys)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := decodePublicKey(block)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, ErrPublicKey
+	}
+	return keys, nil
+}
+
+// decodePublicKey is a helper function that unmarshals a PEM
+// block to an RSA or EC public key, in PKIX or PKCS#1 format.
+func decodePublicKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			return key, nil
+		}
+	case "RSA PUBLIC KEY":
+		key := new(rsa.PublicKey)
+		_, err := asn1.Unmarshal(block.Bytes, key)
+		return key, err
+	}
+	return nil, ErrPublicKey
 }
 
 // encodePrivateKey is a helper function that marshals an RSA
@@ -61,13 +263,19 @@ func encodePrivateKey(privkey *rsa.PrivateKey) string {
 }
 
 // encrypt encrypts a plaintext variable using JOSE with
-// RSA_OAEP and A128GCM algorithms.
-func encrypt(text string, pubKey *rsa.PublicKey) (string, error) {
+// RSA_OAEP or ECDH_ES, for RSA and EC public keys, and
+// A128GCM algorithms.
+func encrypt(text string, pubKey interface{}) (string, error) {
 	var encrypted string
 	var plaintext = []byte(text)
 
+	alg := jose.RSA_OAEP
+	if _, ok := pubKey.(*ecdsa.PublicKey); ok {
+		alg = jose.ECDH_ES
+	}
+
 	// Creates a new encrypter using defaults
-	encrypter, err := jose.NewEncrypter(jose.RSA_OAEP, jose.A128GCM, pubKey)
+	encrypter, err := jose.NewEncrypter(alg, jose.A128GCM, pubKey)
 	if err != nil {
 		return encrypted, err
 	}
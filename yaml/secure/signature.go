@@ -0,0 +1,122 @@
+package secure
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/drone/drone-exec/yaml/shasum"
+	"github.com/square/go-jose"
+)
+
+var (
+	ErrUnsigned  = errors.New("Yaml is not signed")
+	ErrSignature = errors.New("Yaml signature is invalid")
+	ErrUntrusted = errors.New("Yaml is signed with an untrusted key")
+)
+
+// Sign signs the yaml file with the PEM encoded private key
+// and returns the detached JWS signature, in compact format
+// with an empty payload. The public key is embedded in the
+// signature header.
+func Sign(in, privKey string) (string, error) {
+	keys, err := decodePrivateKeys(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	alg := jose.RS256
+	if key, ok := keys[0].(*ecdsa.PrivateKey); ok {
+		switch key.Curve.Params().BitSize {
+		case 384:
+			alg = jose.ES384
+		case 521:
+			alg = jose.ES512
+		default:
+			alg = jose.ES256
+		}
+	}
+
+	signer, err := jose.NewSigner(alg, keys[0])
+	if err != nil {
+		return "", err
+	}
+	object, err := signer.Sign([]byte(in))
+	if err != nil {
+		return "", err
+	}
+	signed, err := object.CompactSerialize()
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(signed, ".")
+	return parts[0] + ".." + parts[2], nil
+}
+
+// VerifyYaml verifies the yaml file with the detached JWS
+// signature and the PEM encoded trusted public keys, or with
+// the checksum if the yaml file is not signed. An unsigned yaml
+// file is only verified by a checksum that is not empty and
+// matches the yaml file, else ErrUnsigned is returned. Other
+// errors are returned as described by Verify.
+func VerifyYaml(in, sig, trusted, checksum string) error {
+	err := Verify(in, sig, trusted)
+	if err == ErrUnsigned && shasum.Verify(in, checksum) {
+		return nil
+	}
+	return err
+}
+
+// Verify verifies the detached JWS signature of the yaml
+// file with the PEM encoded trusted public keys. It returns
+// ErrUnsigned if there is no signature, ErrSignature if the
+// signature is malformed or does not match the yaml file,
+// and ErrUntrusted if the yaml file is signed with a key
+// that is not trusted.
+func Verify(in, sig, trusted string) error {
+	if len(sig) == 0 {
+		return ErrUnsigned
+	}
+	keys, err := decodePublicKeys(trusted)
+	if err != nil {
+		return err
+	}
+
+	// the payload is detached from the signature, and is
+	// re-attached before the signature is parsed.
+	parts := strings.Split(strings.TrimSpace(sig), ".")
+	if len(parts) != 3 || len(parts[1]) != 0 {
+		return ErrSignature
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(in))
+	object, err := jose.ParseSigned(parts[0] + "." + payload + "." + parts[2])
+	if err != nil {
+		return ErrSignature
+	}
+
+	for _, key := range keys {
+		if _, err := object.Verify(key); err == nil {
+			return nil
+		}
+	}
+
+	// if the signature is valid for the embedded public key,
+	// the yaml file is signed with a key that is not trusted.
+	for _, signature := range object.Signatures {
+		jwk := signature.Header.JsonWebKey
+		if jwk == nil {
+			continue
+		}
+		switch jwk.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+		default:
+			continue
+		}
+		if _, err := object.Verify(jwk); err == nil {
+			return ErrUntrusted
+		}
+	}
+	return ErrSignature
+}
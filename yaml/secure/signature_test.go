@@ -0,0 +1,83 @@
+package secure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drone/drone-exec/yaml/shasum"
+	"github.com/franela/goblin"
+)
+
+func Test_Signature(t *testing.T) {
+
+	g := goblin.Goblin(t)
+	g.Describe("Yaml signature", func() {
+
+		g.It("Should sign and verify", func() {
+			for _, pair := range [][]string{
+				{fakePriv, fakePub},
+				{fakePriv, fakePubPKCS1},
+				{fakePrivEC, fakePubEC},
+			} {
+				sig, err := Sign(signedYaml, pair[0])
+				g.Assert(err == nil).IsTrue()
+				g.Assert(Verify(signedYaml, sig, pair[1]) == nil).IsTrue()
+			}
+		})
+
+		g.It("Should verify with any trusted key", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(Verify(signedYaml, sig, fakePubEC+fakePub) == nil).IsTrue()
+		})
+
+		g.It("Should detach the payload", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(strings.Count(sig, ".")).Equal(2)
+			g.Assert(strings.Contains(sig, "..")).IsTrue()
+		})
+
+		g.It("Should error when unsigned", func() {
+			g.Assert(Verify(signedYaml, "", fakePub)).Equal(ErrUnsigned)
+		})
+
+		g.It("Should error when the signature does not match", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(Verify(signedYaml+"\n", sig, fakePub)).Equal(ErrSignature)
+			g.Assert(Verify(signedYaml, "e30..e30", fakePub)).Equal(ErrSignature)
+			g.Assert(Verify(signedYaml, "e30", fakePub)).Equal(ErrSignature)
+		})
+
+		g.It("Should verify an unsigned yaml with the checksum", func() {
+			checksum := shasum.Sum(signedYaml)
+			g.Assert(VerifyYaml(signedYaml, "", fakePub, checksum) == nil).IsTrue()
+			g.Assert(VerifyYaml(signedYaml+"\n", "", fakePub, checksum)).Equal(ErrUnsigned)
+		})
+
+		g.It("Should not verify an unsigned yaml with an empty checksum", func() {
+			g.Assert(VerifyYaml(signedYaml, "", fakePub, "")).Equal(ErrUnsigned)
+		})
+
+		g.It("Should verify a signed yaml without the checksum", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(VerifyYaml(signedYaml, sig, fakePub, "") == nil).IsTrue()
+			g.Assert(VerifyYaml(signedYaml, sig, fakePubEC, shasum.Sum(signedYaml))).Equal(ErrUntrusted)
+		})
+
+		g.It("Should error when signed with an untrusted key", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(Verify(signedYaml, sig, fakePubEC)).Equal(ErrUntrusted)
+		})
+
+		g.It("Should error when the trusted keys are malformed", func() {
+			sig, _ := Sign(signedYaml, fakePriv)
+			g.Assert(Verify(signedYaml, sig, "")).Equal(ErrPublicKey)
+		})
+	})
+}
+
+var signedYaml = `
+build:
+  image: golang
+  commands:
+    - go test
+`
@@ -1,15 +1,102 @@
 package secure
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/drone/drone-exec/glob"
+)
+
+// Secret defines a secret value that may be restricted
+// to the steps and builds matching its scope.
+type Secret struct {
+	Value string `yaml:"value"`
+	Scope `yaml:",inline"`
+}
+
+// UnmarshalYAML unmarshals the secret value from a string, or
+// from a map with the value and its scope.
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	err := unmarshal(&s.Value)
+	if err == nil {
+		return nil
+	}
+	type secret Secret // prevents recursion
+	return unmarshal((*secret)(s))
+}
+
+// Scope restricts a secret to the steps running a matching
+// image, and to builds triggered by a matching event. An
+// empty list does not restrict the secret.
+type Scope struct {
+	Images []string `yaml:"images,omitempty"`
+	Events []string `yaml:"events,omitempty"`
+}
+
+// IsEmpty returns true if the scope does not restrict the
+// secret.
+func (s Scope) IsEmpty() bool {
+	return len(s.Images) == 0 && len(s.Events) == 0
+}
+
+// MatchImage returns true if the image matches one of the
+// image patterns. A pattern without a tag matches any tag
+// of the image.
+func (s Scope) MatchImage(image string) bool {
+	if len(s.Images) == 0 {
+		return true
+	}
+	name := trimTag(image)
+	for _, pattern := range s.Images {
+		if glob.Match(pattern, image) || glob.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchEvent returns true if the event is one of the events.
+func (s Scope) MatchEvent(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// trimTag is a helper function that returns the image name
+// without the tag or digest.
+func trimTag(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
 
 type MapEqualSlice struct {
-	parts map[string]string
+	parts  map[string]string
+	scoped map[string]Secret
 }
 
 func (s *MapEqualSlice) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	s.parts = map[string]string{}
-	err := unmarshal(&s.parts)
+	s.scoped = map[string]Secret{}
+
+	var mapType map[string]Secret
+	err := unmarshal(&mapType)
 	if err == nil {
+		for key, secret := range mapType {
+			s.parts[key] = secret.Value
+			if !secret.Scope.IsEmpty() {
+				s.scoped[key] = secret
+			}
+		}
 		return nil
 	}
 
@@ -32,10 +119,52 @@ func (s *MapEqualSlice) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// Map returns all secret values, including the values
+// restricted by a scope.
 func (s *MapEqualSlice) Map() map[string]string {
 	return s.parts
 }
 
+// Unscoped returns the secret values that are not
+// restricted by a scope.
+func (s *MapEqualSlice) Unscoped() map[string]string {
+	parts := map[string]string{}
+	for key, val := range s.parts {
+		if _, ok := s.scoped[key]; !ok {
+			parts[key] = val
+		}
+	}
+	return parts
+}
+
+// Secrets returns all secrets, including the secrets that are
+// not restricted by a scope.
+func (s *MapEqualSlice) Secrets() map[string]Secret {
+	secrets := map[string]Secret{}
+	for key, val := range s.parts {
+		secrets[key] = Secret{Value: val}
+	}
+	for key, secret := range s.scoped {
+		secrets[key] = secret
+	}
+	return secrets
+}
+
+// Scoped returns the secrets that are restricted by a scope.
+func (s *MapEqualSlice) Scoped() map[string]Secret {
+	return s.scoped
+}
+
 func (s MapEqualSlice) MarshalYAML() (interface{}, error) {
-	return s.parts, nil
+	if len(s.scoped) == 0 {
+		return s.parts, nil
+	}
+	parts := map[string]interface{}{}
+	for key, val := range s.parts {
+		parts[key] = val
+	}
+	for key, secret := range s.scoped {
+		parts[key] = secret
+	}
+	return parts, nil
 }
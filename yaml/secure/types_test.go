@@ -29,5 +29,47 @@ func Test_MapEqualSlice(t *testing.T) {
 			g.Assert(out.Environment.Map()["FOO"]).Equal("BAR")
 			g.Assert(out.Environment.Map()["BAZ"]).Equal("BOO")
 		})
+
+		g.It("Should unmarshal scoped secrets", func() {
+			out := &Secure{}
+			err := yaml.Unmarshal([]byte(scopedYaml), out)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(out.Environment.Map()["FOO"]).Equal("BAR")
+			g.Assert(out.Environment.Map()["PASSWORD"]).Equal("BOO")
+			g.Assert(out.Environment.Unscoped()).Equal(map[string]string{"FOO": "BAR"})
+
+			secret := out.Environment.Scoped()["PASSWORD"]
+			g.Assert(secret.Images).Equal([]string{"plugins/drone-docker"})
+			g.Assert(secret.Events).Equal([]string{"push", "tag"})
+		})
+	})
+
+	g.Describe("Scope", func() {
+
+		g.It("Should match images", func() {
+			scope := Scope{Images: []string{"plugins/drone-docker", "plugins/drone-s3:1.*"}}
+			g.Assert(scope.MatchImage("plugins/drone-docker:latest")).IsTrue()
+			g.Assert(scope.MatchImage("plugins/drone-docker@sha256:abc")).IsTrue()
+			g.Assert(scope.MatchImage("plugins/drone-s3:1.0")).IsTrue()
+			g.Assert(scope.MatchImage("plugins/drone-s3:2.0")).IsFalse()
+			g.Assert(scope.MatchImage("golang:1.5")).IsFalse()
+			g.Assert(Scope{}.MatchImage("golang:1.5")).IsTrue()
+		})
+
+		g.It("Should match events", func() {
+			scope := Scope{Events: []string{"push", "tag"}}
+			g.Assert(scope.MatchEvent("push")).IsTrue()
+			g.Assert(scope.MatchEvent("pull_request")).IsFalse()
+			g.Assert(Scope{}.MatchEvent("pull_request")).IsTrue()
+		})
 	})
 }
+
+var scopedYaml = `
+environment:
+  FOO: BAR
+  PASSWORD:
+    value: BOO
+    images: [ plugins/drone-docker ]
+    events: [ push, tag ]
+`
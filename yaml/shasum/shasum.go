@@ -34,6 +34,19 @@ func Check(in, checksum string) bool {
 	return false
 }
 
+// Verify verifies a file checksum in the same manner as
+// Check, except that an empty checksum is not valid.
+func Verify(in, checksum string) bool {
+	hash, _, _ := split(checksum)
+	return len(hash) != 0 && Check(in, checksum)
+}
+
+// Sum calculates the sha256 checksum of the file in the
+// format verified by Check.
+func Sum(in string) string {
+	return sha256sum(in)
+}
+
 func sha1sum(in string) string {
 	h := sha1.New()
 	io.WriteString(h, in)
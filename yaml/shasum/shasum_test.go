@@ -39,6 +39,12 @@ func TestParse(t *testing.T) {
 			g.Assert(hash).Equal("b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c")
 		})
 
+		g.It("Should calc a checksum verified by check", func() {
+			hash := Sum("foo\n")
+			g.Assert(hash).Equal("b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c")
+			g.Assert(Check("foo\n", hash)).IsTrue()
+		})
+
 		g.It("Should calc a sha512 sum", func() {
 			hash := sha512sum("foo\n")
 			g.Assert(hash).Equal("0cf9180a764aba863a67b6d72f0918bc131c6772642cb2dce5a34f0a702f9470ddc2bf125c12198b1995c233c34b4afd346c54a2334c350a948a51b6e8b4e6b6")
@@ -93,5 +99,11 @@ func TestParse(t *testing.T) {
 			ok := Check("foo\n", "")
 			g.Assert(ok).IsTrue()
 		})
+
+		g.It("Should not verify an empty checksum", func() {
+			g.Assert(Verify("foo\n", "")).IsFalse()
+			g.Assert(Verify("foo\n", " ")).IsFalse()
+			g.Assert(Verify("foo\n", "f1d2d2f924e986ac86fdf7b36c94bcdf32beec15")).IsTrue()
+		})
 	})
 }
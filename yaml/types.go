@@ -3,7 +3,7 @@ package yaml
 // Config is a typed representation of the
 // Yaml configuration file.
 type Config struct {
-	Cache Plugin
+	Cache Cache
 	Clone Plugin
 	Build Build
 
@@ -11,13 +11,19 @@ type Config struct {
 	Publish Pluginslice
 	Deploy  Pluginslice
 	Notify  Pluginslice
+
+	Artifacts Artifacts
 }
 
+// SecretDir is the directory of the in-memory volume where
+// the secret files requested by a step are written.
+const SecretDir = "/run/secrets"
+
 // Container is a typed representation of a
 // docker step in the Yaml configuration file.
 type Container struct {
 	Image       string
-	Pull        bool
+	Pull        PullPolicy
 	Privileged  bool
 	Environment MapEqualSlice
 	Entrypoint  Command
@@ -25,6 +31,8 @@ type Container struct {
 	ExtraHosts  []string `yaml:"extra_hosts"`
 	Volumes     []string
 	Net         string
+	Secrets     Stringorslice
+	SecretFiles map[string]string `yaml:"secret_files"`
 }
 
 // Build is a typed representation of the build
@@ -33,6 +41,24 @@ type Build struct {
 	Container `yaml:",inline"`
 
 	Commands []string
+	Reports  Reports
+	Filter   Filter `yaml:"when"`
+}
+
+// Service is a typed representation of a
+// compose service in the Yaml configuration
+// file.
+type Service struct {
+	Container `yaml:",inline"`
+
+	Filter Filter `yaml:"when"`
+}
+
+// Reports is a typed representation of the test
+// reports produced by a step, as glob patterns
+// relative to the workspace.
+type Reports struct {
+	JUnit Stringorslice
 }
 
 // Plugin is a typed representation of a
@@ -45,6 +71,26 @@ type Plugin struct {
 	Filter Filter `yaml:"when"`
 }
 
+// Cache is a typed representation of the cache
+// section in the Yaml configuration file. The cache
+// is either saved and restored natively, using the
+// key and paths, or by executing a cache plugin.
+type Cache struct {
+	Plugin
+
+	Key      string
+	Fallback []string
+	Paths    []string
+}
+
+// Artifacts is a typed representation of the artifacts
+// section in the Yaml configuration file. The paths are
+// glob patterns relative to the workspace.
+type Artifacts struct {
+	Paths  []string
+	Filter Filter `yaml:"when"`
+}
+
 // Vargs holds unstructured arguments, specific
 // to the plugin, that are used at runtime when
 // executing the plugin.
@@ -54,11 +100,24 @@ type Vargs map[string]interface{}
 // used at runtime to decide if a particular
 // plugin should be executed or skipped.
 type Filter struct {
-	Repo    string
-	Branch  Stringorslice
-	Event   Stringorslice
-	Success string
-	Failure string
-	Change  string
-	Matrix  map[string]string
+	Repo    Constraint
+	Branch  Constraint
+	Event   Constraint
+	Tag     Constraint
+	Status  Stringorslice
+	Success string // DEPRECATED
+	Failure string // DEPRECATED
+	Change  string // DEPRECATED
+	Matrix  map[string]Constraint
+	Path    Constraint
+	Expr    string
+}
+
+// Constraint is a typed representation of the patterns
+// used to filter a value. The value must match one of
+// the included patterns, if any, and none of the excluded
+// patterns.
+type Constraint struct {
+	Include []string
+	Exclude []string
 }
@@ -1,6 +1,7 @@
 package yaml
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -8,6 +9,8 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+var ErrCacheKey = errors.New("Yaml must specify a cache key with the cache paths")
+
 type Command struct {
 	parts []string
 }
@@ -62,6 +65,136 @@ func (s *MapEqualSlice) Slice() []string {
 	return s.parts
 }
 
+// PullPolicy defines when the image for a step is pulled
+// from the registry.
+type PullPolicy string
+
+const (
+	PullIfNotPresent PullPolicy = "if-not-present" // Pull only if missing.
+	PullAlways       PullPolicy = "always"         // Always pull.
+	PullNever        PullPolicy = "never"          // Never pull.
+)
+
+// UnmarshalYAML implements the Unmarshaller interface. For
+// backward compatibility a boolean value is accepted, where
+// true is equivalent to always and false to if-not-present.
+func (p *PullPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var boolType bool
+	err := unmarshal(&boolType)
+	if err == nil {
+		*p = PullIfNotPresent
+		if boolType {
+			*p = PullAlways
+		}
+		return nil
+	}
+
+	var stringType string
+	err = unmarshal(&stringType)
+	if err != nil {
+		return err
+	}
+	switch policy := PullPolicy(stringType); policy {
+	case PullAlways, PullIfNotPresent, PullNever:
+		*p = policy
+		return nil
+	}
+	return fmt.Errorf("Invalid pull policy %q", stringType)
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (c *Cache) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	err := unmarshal(&c.Plugin)
+	if err != nil {
+		return err
+	}
+
+	var native = struct {
+		Key      string
+		Fallback []string `yaml:"fallback_keys"`
+		Paths    []string
+	}{}
+	err = unmarshal(&native)
+	if err != nil {
+		return err
+	}
+	if len(native.Paths) == 0 {
+		return nil
+	}
+	if len(strings.TrimSpace(native.Key)) == 0 {
+		return ErrCacheKey
+	}
+
+	// the native cache attributes are removed from the
+	// variable arguments reserved for the cache plugin.
+	c.Key = native.Key
+	c.Fallback = native.Fallback
+	c.Paths = native.Paths
+	delete(c.Vargs, "key")
+	delete(c.Vargs, "fallback_keys")
+	delete(c.Vargs, "paths")
+	return nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface. The
+// artifacts may be defined as a list of paths.
+func (a *Artifacts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var sliceType []string
+	err := unmarshal(&sliceType)
+	if err == nil {
+		a.Paths = sliceType
+		return nil
+	}
+
+	var structType = struct {
+		Paths  []string
+		Filter Filter `yaml:"when"`
+	}{}
+	err = unmarshal(&structType)
+	if err != nil {
+		return err
+	}
+	a.Paths = structType.Paths
+	a.Filter = structType.Filter
+	return nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface. The
+// constraint may be defined as a pattern or list of patterns,
+// where patterns prefixed with ! are excluded, or as a map
+// of included and excluded patterns.
+func (c *Constraint) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var patterns Stringorslice
+	err := unmarshal(&patterns)
+	if err == nil {
+		for _, pattern := range patterns.Slice() {
+			if strings.HasPrefix(pattern, "!") {
+				c.Exclude = append(c.Exclude, pattern[1:])
+			} else {
+				c.Include = append(c.Include, pattern)
+			}
+		}
+		return nil
+	}
+
+	var structType = struct {
+		Include Stringorslice
+		Exclude Stringorslice
+	}{}
+	err = unmarshal(&structType)
+	if err != nil {
+		return err
+	}
+	c.Include = structType.Include.Slice()
+	c.Exclude = structType.Exclude.Slice()
+	return nil
+}
+
+// Len returns the number of patterns of the Constraint.
+func (c *Constraint) Len() int {
+	return len(c.Include) + len(c.Exclude)
+}
+
 // Stringorslice represents a string or an array of strings.
 // TODO use docker/docker/pkg/stringutils.StrSlice once 1.9.x is released.
 type Stringorslice struct {
@@ -148,7 +281,7 @@ func (s *Pluginslice) Slice() []Plugin {
 // ContainerSlice is a slice of Containers with a custom
 // Yaml unarmshal function to preserve ordering.
 type Containerslice struct {
-	parts []Container
+	parts []Service
 }
 
 func (s *Containerslice) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -164,7 +297,7 @@ func (s *Containerslice) UnmarshalYAML(unmarshal func(interface{}) error) error
 	// unarmshals each item in the mapSlice,
 	// unmarshal and append to the slice.
 	return unmarshalYaml(obj, func(key string, val []byte) error {
-		ctr := Container{}
+		ctr := Service{}
 		err := yaml.Unmarshal(val, &ctr)
 		if err != nil {
 			return err
@@ -177,7 +310,7 @@ func (s *Containerslice) UnmarshalYAML(unmarshal func(interface{}) error) error
 	})
 }
 
-func (s *Containerslice) Slice() []Container {
+func (s *Containerslice) Slice() []Service {
 	return s.parts
 }
 